@@ -25,7 +25,8 @@ const (
 var speeds = []int{57600, 1000000, 115200}
 
 type Client struct {
-	port serial.Port
+	port    serial.Port
+	version string
 
 	rdelay uint8
 	wdelay uint8
@@ -33,6 +34,7 @@ type Client struct {
 	onProgress func(progress float64)
 	onMessage  func(msg string)
 	onError    func(err error)
+	onOutdated func(version string)
 }
 
 func New(rDelay, wDelay uint8) *Client {
@@ -48,6 +50,7 @@ func New(rDelay, wDelay uint8) *Client {
 		onError: func(err error) {
 			log.Println(err.Error())
 		},
+		onOutdated: func(string) {},
 	}
 	return client
 }
@@ -56,6 +59,11 @@ func (c *Client) Port() serial.Port {
 	return c.port
 }
 
+// Version returns the wire version the adapter announced when it was opened.
+func (c *Client) Version() string {
+	return c.version
+}
+
 func (c *Client) Close() error {
 	if c.port == nil {
 		return nil
@@ -78,6 +86,27 @@ func (c *Client) OnError(f func(err error)) *Client {
 	return c
 }
 
+// OnOutdated is called with the adapter's wire version when it is older than
+// the client version passed to Open.
+func (c *Client) OnOutdated(f func(version string)) *Client {
+	c.onOutdated = f
+	return c
+}
+
+// SerialNumber returns the USB serial number of the adapter on portName.
+func SerialNumber(portName string) (string, error) {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return "", err
+	}
+	for _, port := range ports {
+		if port.Name == portName && port.IsUSB && port.SerialNumber != "" {
+			return port.SerialNumber, nil
+		}
+	}
+	return "", fmt.Errorf("no USB serial number for %s", portName)
+}
+
 func ListPorts() (string, []string, error) {
 	var portsList []string
 	ports, err := enumerator.GetDetailedPortsList()
@@ -146,8 +175,10 @@ func (c *Client) openPort(port, versionString string) error {
 		}
 		if semver.Compare(versionString, adapterVersion) > 0 {
 			c.onMessage(fmt.Sprintf("USB adapter is running older wire version (%s). Please use settings to update your adapter firmware", adapterVersion))
+			c.onOutdated(adapterVersion)
 		}
 		c.port = sr
+		c.version = adapterVersion
 		return nil
 	},
 		retry.OnRetry(func(n uint, err error) {
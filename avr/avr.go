@@ -1,12 +1,15 @@
 package avr
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"time"
 
+	"github.com/roffe/eep/adapter"
 	"go.bug.st/serial"
 )
 
@@ -23,28 +26,291 @@ const (
 	cmdLeavePM  = 0x51
 	cmdLoadAddr = 0x55
 	cmdProgPage = 0x64
+	cmdReadPage = 0x74
 	cmdReadSign = 0x75
 
-	pageSize = 128 // ATmega328P flash page in bytes
+	pageSize  = 128       // ATmega328P flash page in bytes
+	flashSize = 32 * 1024 // ATmega328P flash in bytes
 )
 
-func Update(port, board string, cb func(format string, values ...interface{})) ([]byte, error) {
-	baud := 115200
-	if board == "Nano (old bootloader)" {
-		baud = 57600
+// syncTimeout bounds how long sync keeps trying to reach the bootloader.
+var syncTimeout = 5 * time.Second
+
+// Stage is the step of a firmware job an Event belongs to.
+type Stage int
+
+const (
+	StageConnect Stage = iota
+	StageRead
+	StageWrite
+	StageVerify
+	StageReboot
+	StageDone
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageConnect:
+		return "Connecting"
+	case StageRead:
+		return "Reading"
+	case StageWrite:
+		return "Writing"
+	case StageVerify:
+		return "Verifying"
+	case StageReboot:
+		return "Rebooting"
+	case StageDone:
+		return "Done"
 	}
+	return "Unknown"
+}
+
+// Event reports progress of a firmware job. Page, Pages and Bytes are set
+// while reading, writing and verifying; Message carries log text.
+type Event struct {
+	Stage   Stage
+	Page    int
+	Pages   int
+	Bytes   int
+	Message string
+}
+
+func emit(onEvent func(Event), stage Stage, format string, values ...interface{}) {
+	onEvent(Event{Stage: stage, Message: fmt.Sprintf(format, values...)})
+}
+
+// Report describes a finished firmware write.
+type Report struct {
+	Board        string
+	Signature    [3]byte
+	BytesWritten int
+	Pages        int
+	Verified     bool
+	Duration     time.Duration
+	OldVersion   string // wire version before flashing, empty if the adapter didn't answer
+	NewVersion   string // wire version announced after the reboot
+}
 
+func (r *Report) String() string {
+	oldVersion := r.OldVersion
+	if oldVersion == "" {
+		oldVersion = "unknown"
+	}
+	verified := "failed"
+	if r.Verified {
+		verified = "OK"
+	}
+	return fmt.Sprintf("Board: %s\n"+
+		"Signature: %02X %02X %02X\n"+
+		"Wrote %d bytes in %d pages\n"+
+		"Verify: %s\n"+
+		"Took: %s\n"+
+		"Wire version: %s -> %s",
+		r.Board,
+		r.Signature[0], r.Signature[1], r.Signature[2],
+		r.BytesWritten, r.Pages,
+		verified,
+		r.Duration.Round(time.Millisecond),
+		oldVersion, r.NewVersion,
+	)
+}
+
+// Update flashes the bundled adapter firmware and confirms the adapter
+// answers with its wire version afterwards. Cancelling ctx stops between
+// pages and leaves programming mode.
+func Update(ctx context.Context, port, board string, onEvent func(Event)) (*Report, error) {
 	firmware, err := parseIntelHex(firmwareHex)
 	if err != nil {
 		return nil, fmt.Errorf("parse firmware: %w", err)
 	}
+	return flash(ctx, port, board, firmware, onEvent)
+}
 
-	cb("%s", "Opening "+port+" ...")
-	p, err := serial.Open(port, &serial.Mode{BaudRate: baud})
+// Backup reads the application section of the adapter's flash and returns it
+// as Intel HEX, so it can be put back later with Restore.
+func Backup(ctx context.Context, port, board string, onEvent func(Event)) ([]byte, error) {
+	pr, _, err := connect(ctx, port, board, onEvent)
 	if err != nil {
 		return nil, err
 	}
-	defer p.Close()
+	defer pr.close()
+
+	size := appFlashSize(board)
+	pages := (size + pageSize - 1) / pageSize
+	emit(onEvent, StageRead, "Reading %d bytes ...", size)
+	image := make([]byte, 0, size)
+	for addr := 0; addr < size; addr += pageSize {
+		if err := ctx.Err(); err != nil {
+			pr.leave()
+			return nil, err
+		}
+		page, err := pr.readPage(addr, pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("read page at 0x%X: %w", addr, err)
+		}
+		image = append(image, page...)
+		onEvent(Event{Stage: StageRead, Page: addr/pageSize + 1, Pages: pages, Bytes: len(image)})
+	}
+
+	if err := pr.leave(); err != nil {
+		return nil, err
+	}
+	emit(onEvent, StageDone, "Done")
+	return formatIntelHex(image), nil
+}
+
+// Restore writes an Intel HEX image made by Backup back to the adapter. The
+// image is padded with 0xFF to the full application section so no page of the
+// previous firmware is left behind.
+func Restore(ctx context.Context, port, board string, hexData []byte, onEvent func(Event)) (*Report, error) {
+	image, err := parseIntelHex(hexData)
+	if err != nil {
+		return nil, fmt.Errorf("parse backup: %w", err)
+	}
+	size := appFlashSize(board)
+	if len(image) > size {
+		return nil, fmt.Errorf("backup is %d bytes, larger than the %d byte application section", len(image), size)
+	}
+	for len(image) < size {
+		image = append(image, 0xFF)
+	}
+	return flash(ctx, port, board, image, onEvent)
+}
+
+// flash writes and verifies image, checking the adapter's wire version before
+// and after.
+func flash(ctx context.Context, port, board string, image []byte, onEvent func(Event)) (*Report, error) {
+	start := time.Now()
+	report := &Report{Board: board}
+	defer func() { report.Duration = time.Since(start) }()
+
+	emit(onEvent, StageConnect, "Checking current wire version ...")
+	if v, err := probeVersion(port); err == nil {
+		report.OldVersion = v
+		emit(onEvent, StageConnect, "Adapter is running %s", v)
+	} else {
+		emit(onEvent, StageConnect, "Adapter did not answer: %v", err)
+	}
+
+	if err := program(ctx, port, board, image, report, onEvent); err != nil {
+		return report, err
+	}
+
+	emit(onEvent, StageReboot, "Waiting for the adapter to restart ...")
+	v, err := probeVersion(port)
+	if err != nil {
+		return report, fmt.Errorf("adapter did not answer after update: %w", err)
+	}
+	report.NewVersion = v
+	emit(onEvent, StageDone, "Adapter is running %s", v)
+	return report, nil
+}
+
+// Port is the part of serial.Port the programmer needs.
+type Port interface {
+	io.ReadWriteCloser
+	SetReadTimeout(t time.Duration) error
+	SetDTR(dtr bool) error
+	SetRTS(rts bool) error
+	ResetInputBuffer() error
+}
+
+// openPort and probeVersion are variables so tests can put a simulated
+// bootloader behind them.
+var (
+	openPort = func(port string, baud int) (Port, error) {
+		return serial.Open(port, &serial.Mode{BaudRate: baud})
+	}
+
+	// probeVersion opens port as a normal adapter and returns the wire
+	// version it announces.
+	probeVersion = func(port string) (string, error) {
+		c := adapter.New(0, 0).OnMessage(func(string) {})
+		if err := c.Open(port, ""); err != nil {
+			return "", err
+		}
+		defer c.Close()
+		return c.Version(), nil
+	}
+)
+
+// appFlashSize returns the part of flash below the bootloader. Optiboot
+// (Uno, new Nano) takes 512 bytes, the old Nano bootloader takes 2k.
+func appFlashSize(board string) int {
+	if board == "Nano (old bootloader)" {
+		return flashSize - 2048
+	}
+	return flashSize - 512
+}
+
+// program writes image to flash starting at address 0 and reads it back.
+// When ctx is cancelled it leaves programming mode before returning.
+func program(ctx context.Context, port, board string, image []byte, report *Report, onEvent func(Event)) error {
+	pr, sig, err := connect(ctx, port, board, onEvent)
+	if err != nil {
+		return err
+	}
+	defer pr.close()
+	report.Signature = sig
+
+	pages := (len(image) + pageSize - 1) / pageSize
+	emit(onEvent, StageWrite, "Writing %d bytes ...", len(image))
+	for addr := 0; addr < len(image); addr += pageSize {
+		if err := ctx.Err(); err != nil {
+			pr.leave()
+			return err
+		}
+		end := addr + pageSize
+		if end > len(image) {
+			end = len(image)
+		}
+		if err := pr.writePage(addr, image[addr:end]); err != nil {
+			return fmt.Errorf("write page at 0x%X: %w", addr, err)
+		}
+		report.BytesWritten += end - addr
+		report.Pages++
+		onEvent(Event{Stage: StageWrite, Page: report.Pages, Pages: pages, Bytes: report.BytesWritten})
+	}
+
+	emit(onEvent, StageVerify, "Verifying ...")
+	for addr := 0; addr < len(image); addr += pageSize {
+		if err := ctx.Err(); err != nil {
+			pr.leave()
+			return err
+		}
+		end := addr + pageSize
+		if end > len(image) {
+			end = len(image)
+		}
+		page, err := pr.readPage(addr, end-addr)
+		if err != nil {
+			return fmt.Errorf("read page at 0x%X: %w", addr, err)
+		}
+		if !bytes.Equal(page, image[addr:end]) {
+			return fmt.Errorf("verify failed in page at 0x%X", addr)
+		}
+		onEvent(Event{Stage: StageVerify, Page: addr/pageSize + 1, Pages: pages, Bytes: end})
+	}
+	report.Verified = true
+
+	return pr.leave()
+}
+
+// connect opens the port, resets the board into the bootloader, checks the
+// signature and enters programming mode.
+func connect(ctx context.Context, port, board string, onEvent func(Event)) (*programmer, [3]byte, error) {
+	var sig [3]byte
+	baud := 115200
+	if board == "Nano (old bootloader)" {
+		baud = 57600
+	}
+
+	emit(onEvent, StageConnect, "Opening %s ...", port)
+	p, err := openPort(port, baud)
+	if err != nil {
+		return nil, sig, err
+	}
 	// Short per-read timeout so we can hammer GET_SYNC inside the brief
 	// (~1s) Optiboot window without overshooting it.
 	p.SetReadTimeout(200 * time.Millisecond)
@@ -65,55 +331,57 @@ func Update(port, board string, cb func(format string, values ...interface{})) (
 
 	pr := &programmer{p: p}
 
-	cb("%s", "Syncing with bootloader ...")
-	if err := pr.sync(); err != nil {
-		return nil, err
+	emit(onEvent, StageConnect, "Syncing with bootloader ...")
+	if err := pr.sync(ctx); err != nil {
+		p.Close()
+		return nil, sig, err
 	}
 
-	sig, err := pr.cmd([]byte{cmdReadSign}, 3)
+	resp, err := pr.cmd([]byte{cmdReadSign}, 3)
 	if err != nil {
-		return nil, fmt.Errorf("read signature: %w", err)
+		p.Close()
+		return nil, sig, fmt.Errorf("read signature: %w", err)
 	}
-	cb("Device signature: %02X %02X %02X", sig[0], sig[1], sig[2])
+	copy(sig[:], resp)
+	emit(onEvent, StageConnect, "Device signature: %02X %02X %02X", sig[0], sig[1], sig[2])
 	if sig[0] != 0x1E || sig[1] != 0x95 || sig[2] != 0x0F {
-		return nil, fmt.Errorf("unexpected device signature %02X%02X%02X, expected 1E950F (ATmega328P)", sig[0], sig[1], sig[2])
+		p.Close()
+		return nil, sig, fmt.Errorf("unexpected device signature %02X%02X%02X, expected 1E950F (ATmega328P)", sig[0], sig[1], sig[2])
 	}
 
 	if _, err := pr.cmd([]byte{cmdEnterPM}, 0); err != nil {
-		return nil, fmt.Errorf("enter programming mode: %w", err)
+		p.Close()
+		return nil, sig, fmt.Errorf("enter programming mode: %w", err)
 	}
+	return pr, sig, nil
+}
 
-	cb("Writing %d bytes ...", len(firmware))
-	for addr := 0; addr < len(firmware); addr += pageSize {
-		end := addr + pageSize
-		if end > len(firmware) {
-			end = len(firmware)
-		}
-		if err := pr.writePage(addr, firmware[addr:end]); err != nil {
-			return nil, fmt.Errorf("write page at 0x%X: %w", addr, err)
-		}
-		cb("Wrote 0x%04X", addr)
-	}
+type programmer struct {
+	p Port
+}
 
+// leave ends programming mode, which makes Optiboot start the application.
+func (pr *programmer) leave() error {
 	if _, err := pr.cmd([]byte{cmdLeavePM}, 0); err != nil {
-		return nil, fmt.Errorf("leave programming mode: %w", err)
+		return fmt.Errorf("leave programming mode: %w", err)
 	}
-
-	cb("%s", "Done")
-	return nil, nil
+	return nil
 }
 
-type programmer struct {
-	p serial.Port
+func (pr *programmer) close() error {
+	return pr.p.Close()
 }
 
 // sync hammers GET_SYNC until the bootloader answers INSYNC/OK. Optiboot only
 // listens for ~1s after reset, so we send fast with a short read timeout
 // rather than waiting long on any single attempt.
-func (pr *programmer) sync() error {
-	deadline := time.Now().Add(5 * time.Second)
+func (pr *programmer) sync(ctx context.Context) error {
+	deadline := time.Now().Add(syncTimeout)
 	resp := make([]byte, 2)
 	for time.Now().Before(deadline) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		pr.p.ResetInputBuffer()
 		if _, err := pr.p.Write([]byte{cmdGetSync, crcEOP}); err != nil {
 			return err
@@ -140,6 +408,14 @@ func (pr *programmer) writePage(addr int, data []byte) error {
 	return err
 }
 
+func (pr *programmer) readPage(addr, size int) ([]byte, error) {
+	word := addr / 2
+	if _, err := pr.cmd([]byte{cmdLoadAddr, byte(word), byte(word >> 8)}, 0); err != nil {
+		return nil, err
+	}
+	return pr.cmd([]byte{cmdReadPage, byte(size >> 8), byte(size), 'F'}, size)
+}
+
 // cmd sends payload+CRC_EOP, then reads INSYNC, respLen data bytes, and OK.
 func (pr *programmer) cmd(payload []byte, respLen int) ([]byte, error) {
 	if _, err := pr.p.Write(append(payload, crcEOP)); err != nil {
@@ -224,6 +500,33 @@ func parseIntelHex(raw []byte) ([]byte, error) {
 	return out, nil
 }
 
+// formatIntelHex encodes a flat image as Intel HEX with 16 byte data records.
+// Records that are all 0xFF (erased flash) are left out, parseIntelHex pads
+// them back in.
+func formatIntelHex(data []byte) []byte {
+	var out bytes.Buffer
+	for addr := 0; addr < len(data); addr += 16 {
+		end := addr + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[addr:end]
+		if bytes.Count(chunk, []byte{0xFF}) == len(chunk) {
+			continue
+		}
+		rec := []byte{byte(len(chunk)), byte(addr >> 8), byte(addr), 0x00}
+		rec = append(rec, chunk...)
+		var sum byte
+		for _, x := range rec {
+			sum += x
+		}
+		rec = append(rec, -sum)
+		fmt.Fprintf(&out, ":%X\n", rec)
+	}
+	out.WriteString(":00000001FF\n")
+	return out.Bytes()
+}
+
 func splitLines(raw []byte) [][]byte {
 	var lines [][]byte
 	start := 0
@@ -26,3 +26,58 @@ func TestParseIntelHex(t *testing.T) {
 		t.Fatal("expected checksum error")
 	}
 }
+
+func TestFormatIntelHex(t *testing.T) {
+	img := make([]byte, 300)
+	for i := range img {
+		img[i] = 0xFF
+	}
+	copy(img, []byte{0x0C, 0x94, 0x62, 0x00})
+	img[290] = 0x42
+
+	out, err := parseIntelHex(formatIntelHex(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(img) {
+		t.Fatalf("round trip length = %d, want %d", len(out), len(img))
+	}
+	for i := range out {
+		if out[i] != img[i] {
+			t.Fatalf("round trip mismatch at 0x%X: %02X != %02X", i, out[i], img[i])
+		}
+	}
+
+	// the embedded firmware must survive a round trip unchanged
+	fw, _ := parseIntelHex(firmwareHex)
+	again, err := parseIntelHex(formatIntelHex(fw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(fw[:len(again)]) {
+		t.Fatal("firmware round trip mismatch")
+	}
+}
+
+func TestFuses(t *testing.T) {
+	// Arduino Uno defaults: 16MHz low power crystal, 512 byte Optiboot
+	f := Fuses{Low: 0xFF, High: 0xDE, Extended: 0xFD, Lock: 0xCF}
+	if !f.Supported() {
+		t.Fatal("expected fuses to be supported")
+	}
+	if f.BootSize() != 512 || !f.BootReset() {
+		t.Fatalf("boot section = %d reset=%t, want 512 true", f.BootSize(), f.BootReset())
+	}
+	if f.ClockSource() != "low power crystal" || f.ClockDiv8() {
+		t.Fatalf("clock = %s div8=%t", f.ClockSource(), f.ClockDiv8())
+	}
+
+	// old Nano bootloader uses a 2k boot section
+	if (Fuses{High: 0xDA}).BootSize() != 2048 {
+		t.Fatal("expected 2048 byte boot section")
+	}
+
+	if (Fuses{}).Supported() {
+		t.Fatal("all zero fuses means the bootloader did not answer")
+	}
+}
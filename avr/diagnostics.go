@@ -0,0 +1,222 @@
+package avr
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	cmdUniversal = 0x56
+
+	eepromSize = 1024 // ATmega328P EEPROM in bytes
+)
+
+// Fuses holds the fuse and lock bytes as answered by STK_UNIVERSAL.
+type Fuses struct {
+	Low      byte
+	High     byte
+	Extended byte
+	Lock     byte
+}
+
+// Supported reports whether the bootloader actually answered the fuse reads.
+// Optiboot acknowledges STK_UNIVERSAL but always returns 0x00.
+func (f Fuses) Supported() bool {
+	return f != Fuses{}
+}
+
+// BootSize returns the bootloader section size in bytes selected by BOOTSZ.
+func (f Fuses) BootSize() int {
+	switch (f.High >> 1) & 0x03 {
+	case 0x03:
+		return 512
+	case 0x02:
+		return 1024
+	case 0x01:
+		return 2048
+	}
+	return 4096
+}
+
+// BootReset reports whether BOOTRST is programmed, making the chip start in
+// the bootloader after reset.
+func (f Fuses) BootReset() bool {
+	return f.High&0x01 == 0
+}
+
+// ClockSource decodes CKSEL from the low fuse.
+func (f Fuses) ClockSource() string {
+	cksel := f.Low & 0x0F
+	switch {
+	case cksel >= 0x08:
+		return "low power crystal"
+	case cksel >= 0x06:
+		return "full swing crystal"
+	case cksel >= 0x04:
+		return "low frequency crystal"
+	case cksel == 0x03:
+		return "internal 128kHz RC"
+	case cksel == 0x02:
+		return "internal 8MHz RC"
+	case cksel == 0x00:
+		return "external clock"
+	}
+	return "reserved"
+}
+
+// ClockDiv8 reports whether CKDIV8 is programmed.
+func (f Fuses) ClockDiv8() bool {
+	return f.Low&0x80 == 0
+}
+
+// Diagnostics is what can be read from the adapter's microcontroller through
+// the bootloader.
+type Diagnostics struct {
+	Board     string
+	Signature [3]byte
+	Fuses     Fuses
+	// EEPROM is nil when the bootloader can't read it.
+	EEPROM []byte
+}
+
+// EEPROMSupported reports whether the bootloader read the EEPROM. Optiboot
+// built without SUPPORT_EEPROM, like the stock Uno one, ignores the memory
+// type and answers EEPROM reads with flash.
+func (d *Diagnostics) EEPROMSupported() bool {
+	return d.EEPROM != nil
+}
+
+func (d *Diagnostics) String() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "Board: %s\n", d.Board)
+	fmt.Fprintf(&out, "Signature: %02X %02X %02X\n", d.Signature[0], d.Signature[1], d.Signature[2])
+	if !d.Fuses.Supported() {
+		out.WriteString("Fuses: not supported by bootloader\n")
+	} else {
+		f := d.Fuses
+		fmt.Fprintf(&out, "Fuses: low %02X high %02X ext %02X lock %02X\n", f.Low, f.High, f.Extended, f.Lock)
+		fmt.Fprintf(&out, "Clock: %s, CKDIV8 %t\n", f.ClockSource(), f.ClockDiv8())
+		fmt.Fprintf(&out, "Bootloader: %d bytes, BOOTRST %t\n", f.BootSize(), f.BootReset())
+		if want := flashSize - appFlashSize(d.Board); f.BootSize() != want {
+			fmt.Fprintf(&out, "Warning: %s expects a %d byte bootloader section\n", d.Board, want)
+		}
+		if !f.BootReset() {
+			out.WriteString("Warning: BOOTRST not programmed, the bootloader is never started\n")
+		}
+	}
+	if !d.EEPROMSupported() {
+		out.WriteString("EEPROM: read not supported by bootloader")
+		return out.String()
+	}
+	erased := 0
+	for _, b := range d.EEPROM {
+		if b == 0xFF {
+			erased++
+		}
+	}
+	fmt.Fprintf(&out, "EEPROM: %d bytes, %d erased", len(d.EEPROM), erased)
+	return out.String()
+}
+
+// EEPROMDump returns the EEPROM contents as a hex dump.
+func (d *Diagnostics) EEPROMDump() string {
+	if !d.EEPROMSupported() {
+		return "EEPROM read not supported by bootloader"
+	}
+	return hex.Dump(d.EEPROM)
+}
+
+// ReadDiagnostics reads the signature, fuses, lock bits and EEPROM of the
+// adapter through the bootloader. Flash is left untouched.
+func ReadDiagnostics(ctx context.Context, port, board string, onEvent func(Event)) (*Diagnostics, error) {
+	pr, sig, err := connect(ctx, port, board, onEvent)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.close()
+
+	d := &Diagnostics{Board: board, Signature: sig}
+	if d.Fuses, err = pr.readFuses(); err != nil {
+		return nil, fmt.Errorf("read fuses: %w", err)
+	}
+
+	// A bootloader without EEPROM support answers with flash, which is
+	// never blank at the start while there is an application
+	first, err := pr.readEEPROM(0, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("read eeprom at 0x0: %w", err)
+	}
+	flash, err := pr.readPage(0, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("read flash at 0x0: %w", err)
+	}
+	if bytes.Equal(first, flash) && !bytes.Equal(flash, bytes.Repeat([]byte{0xFF}, pageSize)) {
+		emit(onEvent, StageRead, "EEPROM read not supported by bootloader")
+		if err := pr.leave(); err != nil {
+			return nil, err
+		}
+		emit(onEvent, StageDone, "Done")
+		return d, nil
+	}
+
+	pages := eepromSize / pageSize
+	emit(onEvent, StageRead, "Reading %d bytes of EEPROM ...", eepromSize)
+	for addr := 0; addr < eepromSize; addr += pageSize {
+		if err := ctx.Err(); err != nil {
+			pr.leave()
+			return nil, err
+		}
+		b, err := pr.readEEPROM(addr, pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("read eeprom at 0x%X: %w", addr, err)
+		}
+		d.EEPROM = append(d.EEPROM, b...)
+		onEvent(Event{Stage: StageRead, Page: addr/pageSize + 1, Pages: pages, Bytes: len(d.EEPROM)})
+	}
+
+	if err := pr.leave(); err != nil {
+		return nil, err
+	}
+	emit(onEvent, StageDone, "Done")
+	return d, nil
+}
+
+// universal sends a raw 4 byte ISP instruction and returns the answer byte.
+func (pr *programmer) universal(a, b, c, d byte) (byte, error) {
+	resp, err := pr.cmd([]byte{cmdUniversal, a, b, c, d}, 1)
+	if err != nil {
+		return 0, err
+	}
+	return resp[0], nil
+}
+
+func (pr *programmer) readFuses() (Fuses, error) {
+	var f Fuses
+	var err error
+	if f.Low, err = pr.universal(0x50, 0x00, 0x00, 0x00); err != nil {
+		return f, err
+	}
+	if f.High, err = pr.universal(0x58, 0x08, 0x00, 0x00); err != nil {
+		return f, err
+	}
+	if f.Extended, err = pr.universal(0x50, 0x08, 0x00, 0x00); err != nil {
+		return f, err
+	}
+	if f.Lock, err = pr.universal(0x58, 0x00, 0x00, 0x00); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// readEEPROM reads size bytes of EEPROM. The bootloader doubles the loaded
+// address like it does for flash, so the address is sent halved here too.
+func (pr *programmer) readEEPROM(addr, size int) ([]byte, error) {
+	word := addr / 2
+	if _, err := pr.cmd([]byte{cmdLoadAddr, byte(word), byte(word >> 8)}, 0); err != nil {
+		return nil, err
+	}
+	return pr.cmd([]byte{cmdReadPage, byte(size >> 8), byte(size), 'E'}, size)
+}
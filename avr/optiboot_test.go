@@ -0,0 +1,326 @@
+package avr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const stkNoSync = 0x15
+
+// fakeOptiboot simulates an ATmega328P running Optiboot behind a Port. Every
+// Write is handled as one complete STK500v1 command, which is how the
+// programmer sends them.
+type fakeOptiboot struct {
+	flash  []byte
+	eeprom []byte
+	fuses  [4]byte // low, high, extended, lock as answered to STK_UNIVERSAL
+	sig    [3]byte
+
+	addr   int
+	inPM   bool
+	leftPM bool
+	pages  int
+	out    []byte
+
+	ignoreSyncs int  // GET_SYNC requests to leave unanswered
+	noSyncOn    byte // command to answer with STK_NOSYNC
+	corruptRead bool // flip a bit in every READ_PAGE answer
+	noEEPROM    bool // built without SUPPORT_EEPROM, answer EEPROM reads with flash
+}
+
+func newFakeOptiboot() *fakeOptiboot {
+	return &fakeOptiboot{
+		flash:  bytes.Repeat([]byte{0xFF}, flashSize),
+		eeprom: bytes.Repeat([]byte{0xFF}, eepromSize),
+		sig:    [3]byte{0x1E, 0x95, 0x0F},
+	}
+}
+
+func (f *fakeOptiboot) Write(b []byte) (int, error) {
+	if len(b) < 2 || b[len(b)-1] != crcEOP {
+		return len(b), nil // real Optiboot would watchdog reset, we just stay silent
+	}
+	if b[0] == cmdGetSync && f.ignoreSyncs > 0 {
+		f.ignoreSyncs--
+		return len(b), nil
+	}
+	if b[0] == f.noSyncOn {
+		f.out = append(f.out, stkNoSync)
+		return len(b), nil
+	}
+
+	var resp []byte
+	switch b[0] {
+	case cmdGetSync:
+	case cmdReadSign:
+		resp = f.sig[:]
+	case cmdEnterPM:
+		f.inPM = true
+	case cmdLeavePM:
+		f.inPM = false
+		f.leftPM = true
+	case cmdLoadAddr:
+		f.addr = (int(b[1]) | int(b[2])<<8) * 2 // Optiboot converts words to bytes
+	case cmdProgPage:
+		size := int(b[1])<<8 | int(b[2])
+		copy(f.flash[f.addr:], b[4:4+size])
+		f.pages++
+	case cmdReadPage:
+		size := int(b[1])<<8 | int(b[2])
+		mem := f.flash
+		if b[3] == 'E' && !f.noEEPROM {
+			mem = f.eeprom
+		}
+		resp = append([]byte{}, mem[f.addr:f.addr+size]...)
+		if f.corruptRead {
+			resp[0] ^= 0x01
+		}
+	case cmdUniversal:
+		switch {
+		case b[1] == 0x50 && b[2] == 0x00:
+			resp = f.fuses[0:1]
+		case b[1] == 0x58 && b[2] == 0x08:
+			resp = f.fuses[1:2]
+		case b[1] == 0x50 && b[2] == 0x08:
+			resp = f.fuses[2:3]
+		default:
+			resp = f.fuses[3:4]
+		}
+	default:
+		f.out = append(f.out, stkNoSync)
+		return len(b), nil
+	}
+	f.out = append(f.out, stkInsync)
+	f.out = append(f.out, resp...)
+	f.out = append(f.out, stkOK)
+	return len(b), nil
+}
+
+// Read returns what is queued, or nothing like a serial read timing out.
+func (f *fakeOptiboot) Read(b []byte) (int, error) {
+	n := copy(b, f.out)
+	f.out = f.out[n:]
+	return n, nil
+}
+
+func (f *fakeOptiboot) Close() error                         { return nil }
+func (f *fakeOptiboot) SetReadTimeout(t time.Duration) error { return nil }
+func (f *fakeOptiboot) SetDTR(dtr bool) error                { return nil }
+func (f *fakeOptiboot) SetRTS(rts bool) error                { return nil }
+func (f *fakeOptiboot) ResetInputBuffer() error {
+	f.out = nil
+	return nil
+}
+
+// useFake puts dev behind openPort and makes probeVersion answer the given
+// versions in order.
+func useFake(t *testing.T, dev *fakeOptiboot, versions ...string) {
+	t.Helper()
+	oldOpen, oldProbe, oldTimeout := openPort, probeVersion, syncTimeout
+	t.Cleanup(func() {
+		openPort, probeVersion, syncTimeout = oldOpen, oldProbe, oldTimeout
+	})
+	openPort = func(port string, baud int) (Port, error) {
+		return dev, nil
+	}
+	probeVersion = func(port string) (string, error) {
+		if len(versions) == 0 {
+			return "", errors.New("Got no response from adapter")
+		}
+		v := versions[0]
+		versions = versions[1:]
+		return v, nil
+	}
+	syncTimeout = 200 * time.Millisecond
+}
+
+func nopEvent(Event) {}
+
+func TestUpdate(t *testing.T) {
+	dev := newFakeOptiboot()
+	useFake(t, dev, "v2.0.16", "v2.0.17")
+
+	report, err := Update(context.Background(), "fake", "Uno", nopEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw, _ := parseIntelHex(firmwareHex)
+	if !bytes.Equal(dev.flash[:len(fw)], fw) {
+		t.Fatal("flash does not match firmware")
+	}
+	if !dev.leftPM {
+		t.Fatal("programming mode was not left")
+	}
+	wantPages := (len(fw) + pageSize - 1) / pageSize
+	if report.Pages != wantPages || report.BytesWritten != len(fw) || !report.Verified {
+		t.Fatalf("report = %+v, want %d pages of %d bytes verified", report, wantPages, len(fw))
+	}
+	if report.Signature != dev.sig {
+		t.Fatalf("signature = %X", report.Signature)
+	}
+	if report.OldVersion != "v2.0.16" || report.NewVersion != "v2.0.17" {
+		t.Fatalf("versions = %q -> %q", report.OldVersion, report.NewVersion)
+	}
+}
+
+func TestUpdateSyncRetries(t *testing.T) {
+	dev := newFakeOptiboot()
+	dev.ignoreSyncs = 5
+	useFake(t, dev, "", "v2.0.17")
+
+	if _, err := Update(context.Background(), "fake", "Uno", nopEvent); err != nil {
+		t.Fatal(err)
+	}
+	if dev.ignoreSyncs != 0 {
+		t.Fatalf("%d syncs left unanswered", dev.ignoreSyncs)
+	}
+}
+
+func TestUpdateNoBootloader(t *testing.T) {
+	dev := newFakeOptiboot()
+	dev.ignoreSyncs = 1 << 30
+	useFake(t, dev)
+
+	_, err := Update(context.Background(), "fake", "Uno", nopEvent)
+	if err == nil || !strings.Contains(err.Error(), "could not sync") {
+		t.Fatalf("err = %v, want sync failure", err)
+	}
+	if dev.pages != 0 {
+		t.Fatal("wrote pages without sync")
+	}
+}
+
+func TestUpdateWrongSignature(t *testing.T) {
+	dev := newFakeOptiboot()
+	dev.sig = [3]byte{0x1E, 0x95, 0x14} // ATmega328 without the P
+	useFake(t, dev)
+
+	_, err := Update(context.Background(), "fake", "Uno", nopEvent)
+	if err == nil || !strings.Contains(err.Error(), "unexpected device signature 1E9514") {
+		t.Fatalf("err = %v, want signature error", err)
+	}
+	if dev.inPM || dev.pages != 0 {
+		t.Fatal("entered programming mode on wrong device")
+	}
+}
+
+func TestUpdateOutOfSync(t *testing.T) {
+	dev := newFakeOptiboot()
+	dev.noSyncOn = cmdProgPage
+	useFake(t, dev)
+
+	_, err := Update(context.Background(), "fake", "Uno", nopEvent)
+	if err == nil || !strings.Contains(err.Error(), "expected INSYNC, got 0x15") {
+		t.Fatalf("err = %v, want out of sync error", err)
+	}
+}
+
+func TestUpdateVerifyMismatch(t *testing.T) {
+	dev := newFakeOptiboot()
+	dev.corruptRead = true
+	useFake(t, dev)
+
+	report, err := Update(context.Background(), "fake", "Uno", nopEvent)
+	if err == nil || !strings.Contains(err.Error(), "verify failed in page at 0x0") {
+		t.Fatalf("err = %v, want verify error", err)
+	}
+	if report.Verified {
+		t.Fatal("report claims verified")
+	}
+}
+
+func TestUpdateCancel(t *testing.T) {
+	dev := newFakeOptiboot()
+	useFake(t, dev)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := Update(ctx, "fake", "Uno", func(ev Event) {
+		if ev.Stage == StageWrite && ev.Page == 2 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if dev.pages != 2 {
+		t.Fatalf("wrote %d pages after cancel, want 2", dev.pages)
+	}
+	if !dev.leftPM {
+		t.Fatal("programming mode was not left after cancel")
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	dev := newFakeOptiboot()
+	for i := 0; i < 5000; i++ {
+		dev.flash[i] = byte(i * 7)
+	}
+	orig := append([]byte{}, dev.flash...)
+	useFake(t, dev, "v2.0.17", "v2.0.17")
+
+	backup, err := Backup(context.Background(), "fake", "Uno", nopEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// scribble over the application section, including past the old image
+	for i := 0; i < 8000; i++ {
+		dev.flash[i] = 0x42
+	}
+	if _, err := Restore(context.Background(), "fake", "Uno", backup, nopEvent); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dev.flash, orig) {
+		t.Fatal("restored flash does not match the backup")
+	}
+}
+
+func TestReadDiagnostics(t *testing.T) {
+	dev := newFakeOptiboot()
+	dev.fuses = [4]byte{0xFF, 0xDE, 0xFD, 0xCF}
+	for i := range dev.eeprom {
+		dev.eeprom[i] = byte(i)
+	}
+	useFake(t, dev)
+
+	d, err := ReadDiagnostics(context.Background(), "fake", "Uno", nopEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d.EEPROM, dev.eeprom) {
+		t.Fatal("eeprom mismatch")
+	}
+	if d.Fuses != (Fuses{Low: 0xFF, High: 0xDE, Extended: 0xFD, Lock: 0xCF}) {
+		t.Fatalf("fuses = %+v", d.Fuses)
+	}
+	if strings.Contains(d.String(), "Warning") {
+		t.Fatalf("unexpected warning for a stock Uno:\n%s", d)
+	}
+}
+
+func TestReadDiagnosticsNoEEPROM(t *testing.T) {
+	dev := newFakeOptiboot()
+	dev.noEEPROM = true
+	fw, _ := parseIntelHex(firmwareHex)
+	copy(dev.flash, fw)
+	useFake(t, dev)
+
+	d, err := ReadDiagnostics(context.Background(), "fake", "Uno", nopEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.EEPROMSupported() || d.EEPROM != nil {
+		t.Fatalf("flash taken for EEPROM: % X", d.EEPROM[:16])
+	}
+	if !strings.Contains(d.String(), "EEPROM: read not supported by bootloader") || d.EEPROMDump() != "EEPROM read not supported by bootloader" {
+		t.Fatalf("unsupported EEPROM not reported:\n%s", d)
+	}
+	if !dev.leftPM {
+		t.Fatal("programming mode was not left")
+	}
+}
@@ -0,0 +1,171 @@
+package eeprom
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxUndo bounds the undo history.
+const maxUndo = 1000
+
+// ErrRange is returned for writes outside the buffer.
+var ErrRange = errors.New("write outside buffer")
+
+// Buffer is a fixed size byte buffer with multi level undo and redo.
+type Buffer struct {
+	data []byte
+	undo []change
+	redo []change
+}
+
+type change struct {
+	off      int
+	old, new []byte
+}
+
+func (c change) span() Span {
+	return Span{Start: c.off, End: c.off + len(c.new) - 1}
+}
+
+// NewBuffer returns a buffer holding a copy of data.
+func NewBuffer(data []byte) *Buffer {
+	return &Buffer{data: bytes.Clone(data)}
+}
+
+// Bytes returns a copy of the buffer contents.
+func (b *Buffer) Bytes() []byte {
+	return bytes.Clone(b.data)
+}
+
+func (b *Buffer) Len() int {
+	return len(b.data)
+}
+
+func (b *Buffer) At(pos int) byte {
+	return b.data[pos]
+}
+
+// Slice returns a copy of the bytes in s.
+func (b *Buffer) Slice(s Span) []byte {
+	return bytes.Clone(b.data[s.Start : s.End+1])
+}
+
+// Write overwrites the buffer at off with p as one undo step. Writes that
+// don't change anything aren't recorded.
+func (b *Buffer) Write(off int, p []byte) error {
+	if off < 0 || off+len(p) > len(b.data) {
+		return fmt.Errorf("%d bytes at 0x%X: %w", len(p), off, ErrRange)
+	}
+	if len(p) == 0 || bytes.Equal(b.data[off:off+len(p)], p) {
+		return nil
+	}
+	c := change{off: off, old: bytes.Clone(b.data[off : off+len(p)]), new: bytes.Clone(p)}
+	copy(b.data[off:], p)
+	b.undo = append(b.undo, c)
+	if len(b.undo) > maxUndo {
+		b.undo = b.undo[len(b.undo)-maxUndo:]
+	}
+	b.redo = nil
+	return nil
+}
+
+// Amend overwrites the buffer at off with p like Write, but folds it into
+// the last undo step when that wrote the same bytes, so a byte typed as two
+// hex digits is undone in one go.
+func (b *Buffer) Amend(off int, p []byte) error {
+	if len(b.undo) == 0 {
+		return b.Write(off, p)
+	}
+	last := &b.undo[len(b.undo)-1]
+	if last.off != off || len(last.new) != len(p) {
+		return b.Write(off, p)
+	}
+	copy(b.data[off:], p)
+	last.new = bytes.Clone(p)
+	if bytes.Equal(last.old, last.new) {
+		b.undo = b.undo[:len(b.undo)-1]
+	}
+	b.redo = nil
+	return nil
+}
+
+// Set overwrites the whole buffer with data without an undo step, for
+// changes undone elsewhere. The undo history is kept.
+func (b *Buffer) Set(data []byte) error {
+	if len(data) != len(b.data) {
+		return fmt.Errorf("set %d bytes with %d: %w", len(b.data), len(data), ErrRange)
+	}
+	copy(b.data, data)
+	return nil
+}
+
+// Replace overwrites the whole buffer with data, which must be the same
+// length, as a single undo step covering the bytes that differ.
+func (b *Buffer) Replace(data []byte) error {
+	if len(data) != len(b.data) {
+		return fmt.Errorf("replace %d bytes with %d: %w", len(b.data), len(data), ErrRange)
+	}
+	first, last := -1, -1
+	for i := range data {
+		if data[i] != b.data[i] {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+	return b.Write(first, data[first:last+1])
+}
+
+func (b *Buffer) CanUndo() bool {
+	return len(b.undo) > 0
+}
+
+func (b *Buffer) CanRedo() bool {
+	return len(b.redo) > 0
+}
+
+// Undo reverts the last write and returns the span it touched.
+func (b *Buffer) Undo() (Span, bool) {
+	if len(b.undo) == 0 {
+		return Span{}, false
+	}
+	c := b.undo[len(b.undo)-1]
+	b.undo = b.undo[:len(b.undo)-1]
+	copy(b.data[c.off:], c.old)
+	b.redo = append(b.redo, c)
+	return c.span(), true
+}
+
+// Redo reapplies the last undone write and returns the span it touched.
+func (b *Buffer) Redo() (Span, bool) {
+	if len(b.redo) == 0 {
+		return Span{}, false
+	}
+	c := b.redo[len(b.redo)-1]
+	b.redo = b.redo[:len(b.redo)-1]
+	copy(b.data[c.off:], c.new)
+	b.undo = append(b.undo, c)
+	return c.span(), true
+}
+
+// ParseHex decodes hex text as copied from a hex view. Whitespace and 0x
+// prefixes are ignored.
+func ParseHex(s string) ([]byte, error) {
+	var sb strings.Builder
+	for _, f := range strings.Fields(s) {
+		f = strings.TrimPrefix(strings.TrimPrefix(f, "0x"), "0X")
+		sb.WriteString(f)
+	}
+	b, err := hex.DecodeString(sb.String())
+	if err != nil {
+		return nil, errors.New("invalid hex")
+	}
+	return b, nil
+}
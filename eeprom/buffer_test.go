@@ -0,0 +1,153 @@
+package eeprom
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestBufferUndoRedo(t *testing.T) {
+	b := NewBuffer([]byte{0, 1, 2, 3, 4, 5})
+	steps := []struct {
+		off int
+		p   []byte
+	}{
+		{0, []byte{0xaa}},
+		{2, []byte{0xbb, 0xcc}},
+		{5, []byte{0xdd}},
+	}
+	var states [][]byte
+	states = append(states, b.Bytes())
+	for _, s := range steps {
+		if err := b.Write(s.off, s.p); err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, b.Bytes())
+	}
+	if want := []byte{0xaa, 1, 0xbb, 0xcc, 4, 0xdd}; !bytes.Equal(b.Bytes(), want) {
+		t.Fatalf("after writes = %X, want %X", b.Bytes(), want)
+	}
+
+	for i := len(steps) - 1; i >= 0; i-- {
+		span, ok := b.Undo()
+		if !ok {
+			t.Fatalf("undo %d failed", i)
+		}
+		if span.Start != steps[i].off || span.Len() != len(steps[i].p) {
+			t.Errorf("undo %d span = %+v", i, span)
+		}
+		if !bytes.Equal(b.Bytes(), states[i]) {
+			t.Errorf("undo %d = %X, want %X", i, b.Bytes(), states[i])
+		}
+	}
+	if b.CanUndo() {
+		t.Error("CanUndo after undoing everything")
+	}
+	for i := range steps {
+		if _, ok := b.Redo(); !ok {
+			t.Fatalf("redo %d failed", i)
+		}
+		if !bytes.Equal(b.Bytes(), states[i+1]) {
+			t.Errorf("redo %d = %X, want %X", i, b.Bytes(), states[i+1])
+		}
+	}
+
+	// A new write drops the redo history
+	b.Undo()
+	b.Write(1, []byte{0xee})
+	if b.CanRedo() {
+		t.Error("CanRedo after a new write")
+	}
+}
+
+func TestBufferWriteRange(t *testing.T) {
+	b := NewBuffer(make([]byte, 4))
+	if err := b.Write(3, []byte{1, 2}); !errors.Is(err, ErrRange) {
+		t.Errorf("Write past end = %v", err)
+	}
+	if err := b.Write(-1, []byte{1}); !errors.Is(err, ErrRange) {
+		t.Errorf("Write before start = %v", err)
+	}
+	if err := b.Write(0, []byte{0}); err != nil || b.CanUndo() {
+		t.Errorf("no-op write recorded: %v", err)
+	}
+}
+
+func TestBufferReplace(t *testing.T) {
+	b := NewBuffer([]byte{0, 1, 2, 3, 4})
+	if err := b.Replace([]byte{0, 9, 2, 9, 4}); err != nil {
+		t.Fatal(err)
+	}
+	span, _ := b.Undo()
+	if span != (Span{1, 3}) {
+		t.Errorf("replace span = %+v, want 1-3", span)
+	}
+	if err := b.Replace([]byte{1}); !errors.Is(err, ErrRange) {
+		t.Errorf("Replace short = %v", err)
+	}
+}
+
+func TestBufferAmend(t *testing.T) {
+	b := NewBuffer([]byte{0x12, 0x34})
+	b.Write(0, []byte{0x52})
+	b.Amend(0, []byte{0x56})
+	if !bytes.Equal(b.Bytes(), []byte{0x56, 0x34}) {
+		t.Fatalf("after amend = %X", b.Bytes())
+	}
+	if _, ok := b.Undo(); !ok || !bytes.Equal(b.Bytes(), []byte{0x12, 0x34}) || b.CanUndo() {
+		t.Fatalf("amended write not undone in one step: %X", b.Bytes())
+	}
+
+	// Back to where it started leaves no step
+	b = NewBuffer([]byte{0x12, 0x34})
+	b.Write(0, []byte{0x52})
+	b.Amend(0, []byte{0x12})
+	if b.CanUndo() {
+		t.Error("no-op amend left an undo step")
+	}
+
+	// Elsewhere it is a write of its own
+	b.Write(0, []byte{0xAA})
+	b.Amend(1, []byte{0xBB})
+	b.Undo()
+	if !bytes.Equal(b.Bytes(), []byte{0xAA, 0x34}) {
+		t.Errorf("amend at another offset = %X", b.Bytes())
+	}
+}
+
+func TestBufferSet(t *testing.T) {
+	b := NewBuffer([]byte{0, 1, 2})
+	b.Write(0, []byte{9})
+	if err := b.Set([]byte{9, 8, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), []byte{9, 8, 2}) {
+		t.Fatalf("after set = %X", b.Bytes())
+	}
+	b.Undo()
+	if !bytes.Equal(b.Bytes(), []byte{0, 8, 2}) || b.CanUndo() {
+		t.Errorf("set recorded an undo step: %X", b.Bytes())
+	}
+	if err := b.Set([]byte{1}); !errors.Is(err, ErrRange) {
+		t.Errorf("Set short = %v", err)
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	for in, want := range map[string][]byte{
+		"DEADBEEF":      {0xde, 0xad, 0xbe, 0xef},
+		"de ad\nbe\tef": {0xde, 0xad, 0xbe, 0xef},
+		"0xDE 0xAD":     {0xde, 0xad},
+		"":              {},
+	} {
+		got, err := ParseHex(in)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("ParseHex(%q) = %X, %v, want %X", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"ABC", "XY"} {
+		if _, err := ParseHex(bad); err == nil {
+			t.Errorf("ParseHex(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package eeprom
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ByteChange is one byte that differs between two images. A side past the
+// end of its image is empty.
+type ByteChange struct {
+	Offset int    `json:"offset"`
+	A      string `json:"a"`
+	B      string `json:"b"`
+}
+
+// RegionChange summarises the changed bytes of one region. Changes outside
+// every region are collected under an empty name.
+type RegionChange struct {
+	Name    string `json:"name"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Changed int    `json:"changed"`
+	A       string `json:"a,omitempty"`
+	B       string `json:"b,omitempty"`
+}
+
+func (rc RegionChange) String() string {
+	if rc.Name == "" {
+		return fmt.Sprintf("%d byte(s) outside any region changed", rc.Changed)
+	}
+	return fmt.Sprintf("%s changed (%d of %d bytes)", rc.Name, rc.Changed, rc.End-rc.Start+1)
+}
+
+// Diff is the byte and region level difference between two images.
+type Diff struct {
+	NameA   string         `json:"a"`
+	NameB   string         `json:"b"`
+	SizeA   int            `json:"size_a"`
+	SizeB   int            `json:"size_b"`
+	Bytes   []ByteChange   `json:"bytes"`
+	Regions []RegionChange `json:"regions"`
+
+	changed map[int]bool
+}
+
+// Compare diffs image a against b and summarises the changes per region.
+func Compare(nameA string, a []byte, nameB string, b []byte, regions Layout) *Diff {
+	d := &Diff{
+		NameA:   nameA,
+		NameB:   nameB,
+		SizeA:   len(a),
+		SizeB:   len(b),
+		Bytes:   []ByteChange{},
+		Regions: []RegionChange{},
+		changed: make(map[int]bool),
+	}
+	side := func(img []byte, pos int) string {
+		if pos >= len(img) {
+			return ""
+		}
+		return fmt.Sprintf("%02X", img[pos])
+	}
+	for pos := 0; pos < max(len(a), len(b)); pos++ {
+		ba, bb := side(a, pos), side(b, pos)
+		if ba != bb {
+			d.Bytes = append(d.Bytes, ByteChange{Offset: pos, A: ba, B: bb})
+			d.changed[pos] = true
+		}
+	}
+
+	hexSpan := func(img []byte, s Span) string {
+		if s.Start >= len(img) {
+			return ""
+		}
+		return fmt.Sprintf("%X", img[s.Start:min(s.End+1, len(img))])
+	}
+	mapped := make(map[int]bool)
+	for _, r := range regions {
+		n := 0
+		for pos := r.Start; pos <= r.End; pos++ {
+			if d.changed[pos] {
+				n++
+				mapped[pos] = true
+			}
+		}
+		if n > 0 {
+			d.Regions = append(d.Regions, RegionChange{
+				Name:    r.Name,
+				Start:   r.Start,
+				End:     r.End,
+				Changed: n,
+				A:       hexSpan(a, r.Span),
+				B:       hexSpan(b, r.Span),
+			})
+		}
+	}
+	if unmapped := len(d.changed) - len(mapped); unmapped > 0 {
+		d.Regions = append(d.Regions, RegionChange{Changed: unmapped})
+	}
+	return d
+}
+
+// Equal reports whether the images are identical.
+func (d *Diff) Equal() bool {
+	return len(d.Bytes) == 0
+}
+
+// Changed reports whether the byte at pos differs.
+func (d *Diff) Changed(pos int) bool {
+	return d.changed[pos]
+}
+
+// Text renders the diff as a readable report.
+func (d *Diff) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "A: %s (%d bytes)\n", d.NameA, d.SizeA)
+	fmt.Fprintf(&sb, "B: %s (%d bytes)\n\n", d.NameB, d.SizeB)
+	if d.Equal() {
+		sb.WriteString("Images are identical\n")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "%d byte(s) differ\n\n", len(d.Bytes))
+	for _, rc := range d.Regions {
+		sb.WriteString(rc.String() + "\n")
+		if rc.Name != "" {
+			fmt.Fprintf(&sb, "  A: %s\n  B: %s\n", rc.A, rc.B)
+		}
+	}
+	sb.WriteString("\nOffset  A   B\n")
+	for _, bc := range d.Bytes {
+		fmt.Fprintf(&sb, "0x%03X   %-2s  %-2s\n", bc.Offset, dash(bc.A), dash(bc.B))
+	}
+	return sb.String()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "--"
+	}
+	return s
+}
+
+// JSON renders the diff as indented JSON.
+func (d *Diff) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
@@ -0,0 +1,72 @@
+package eeprom
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	a := make([]byte, 0x30)
+	b := make([]byte, 0x30)
+	b[0x11] = 0x01 // Data #1
+	b[0x17] = 0x02 // Data #2
+	b[0x18] = 0x03
+	b[0x2f] = 0xff // outside any region
+
+	d := Compare("before.bin", a, "after.bin", b, testLayout)
+	if d.Equal() || len(d.Bytes) != 4 {
+		t.Fatalf("bytes = %+v", d.Bytes)
+	}
+	if !d.Changed(0x17) || d.Changed(0x16) {
+		t.Error("Changed")
+	}
+
+	var got []string
+	for _, rc := range d.Regions {
+		got = append(got, rc.String())
+	}
+	want := []string{
+		"Data #1 changed (1 of 4 bytes)",
+		"Data #2 changed (2 of 4 bytes)",
+		"1 byte(s) outside any region changed",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("regions = %q, want %q", got, want)
+	}
+	if d.Regions[1].A != "00000000" || d.Regions[1].B != "00020300" {
+		t.Errorf("Data #2 = %s -> %s", d.Regions[1].A, d.Regions[1].B)
+	}
+
+	text := d.Text()
+	for _, s := range []string{"A: before.bin (48 bytes)", "4 byte(s) differ", "0x017   00  02"} {
+		if !strings.Contains(text, s) {
+			t.Errorf("text report misses %q:\n%s", s, text)
+		}
+	}
+
+	js, err := d.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Diff
+	if err := json.Unmarshal(js, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.NameB != "after.bin" || len(back.Bytes) != 4 || len(back.Regions) != 3 || back.Bytes[0].Offset != 0x11 {
+		t.Errorf("JSON round trip = %+v", back)
+	}
+}
+
+func TestCompareSizes(t *testing.T) {
+	d := Compare("a", []byte{1, 2}, "b", []byte{1, 2, 3}, nil)
+	if len(d.Bytes) != 1 || d.Bytes[0] != (ByteChange{Offset: 2, A: "", B: "03"}) {
+		t.Errorf("bytes = %+v", d.Bytes)
+	}
+	if !strings.Contains(d.Text(), "0x002   --  03") {
+		t.Errorf("text:\n%s", d.Text())
+	}
+	if d := Compare("a", []byte{1}, "b", []byte{1}, nil); !d.Equal() || !strings.Contains(d.Text(), "identical") {
+		t.Error("identical images")
+	}
+}
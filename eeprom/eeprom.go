@@ -0,0 +1,317 @@
+// Package eeprom describes the field layout of 93Cx6 EEPROM images, like the
+// CIM and MIU dumps, and edits them field by field keeping mirrored banks and
+// checksums consistent.
+package eeprom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Size is the size of a CIM EEPROM image.
+const Size = 512
+
+// Kind says how a region's bytes are shown and entered.
+type Kind int
+
+const (
+	// Hex is raw bytes, shown as hex.
+	Hex Kind = iota
+	// Uint is a big endian unsigned number of up to 8 bytes.
+	Uint
+	// ASCII is printable text.
+	ASCII
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Uint:
+		return "number"
+	case ASCII:
+		return "text"
+	default:
+		return "hex"
+	}
+}
+
+// Span is an inclusive byte range.
+type Span struct {
+	Start int
+	End   int
+}
+
+func (s Span) Len() int {
+	return s.End - s.Start + 1
+}
+
+func (s Span) Contains(pos int) bool {
+	return pos >= s.Start && pos <= s.End
+}
+
+func (s Span) Overlaps(o Span) bool {
+	return s.Start <= o.End && o.Start <= s.End
+}
+
+// Region is a named field of the image.
+type Region struct {
+	Name string
+	Span
+	Kind Kind
+	// Checksum is the span a CRC region covers, nil for other regions.
+	Checksum *Span
+	// Color is the region's colour in the hex view.
+	Color color.RGBA
+}
+
+func (r Region) String() string {
+	return fmt.Sprintf("%s [0x%03X-0x%03X]", r.Name, r.Start, r.End)
+}
+
+// Format decodes b, which must be the region's bytes, for display.
+func (r Region) Format(b []byte) string {
+	switch r.Kind {
+	case Uint:
+		if len(b) <= 8 {
+			var v uint64
+			for _, c := range b {
+				v = v<<8 | uint64(c)
+			}
+			return strconv.FormatUint(v, 10)
+		}
+	case ASCII:
+		if printable(b) {
+			return string(b)
+		}
+	}
+	if r.Checksum != nil && len(b) == 2 {
+		return fmt.Sprintf("%04X", binary.BigEndian.Uint16(b))
+	}
+	return fmt.Sprintf("%X", b)
+}
+
+// Parse encodes a typed value for the region. It accepts what Format
+// returns.
+func (r Region) Parse(s string) ([]byte, error) {
+	switch r.Kind {
+	case Uint:
+		v, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		if r.Len() < 8 && v>>(8*r.Len()) != 0 {
+			return nil, fmt.Errorf("%s: %d does not fit in %d bytes", r.Name, v, r.Len())
+		}
+		out := make([]byte, r.Len())
+		for i := len(out) - 1; i >= 0; i-- {
+			out[i] = byte(v)
+			v >>= 8
+		}
+		return out, nil
+	case ASCII:
+		if len(s) != r.Len() {
+			return nil, fmt.Errorf("%s: want %d characters, got %d", r.Name, r.Len(), len(s))
+		}
+		if !printable([]byte(s)) {
+			return nil, fmt.Errorf("%s: only printable ASCII is allowed", r.Name)
+		}
+		return []byte(s), nil
+	}
+	return r.ParseHex(s)
+}
+
+// ParseHex decodes a hex string of exactly the region's length. Whitespace
+// is ignored.
+func (r Region) ParseHex(s string) ([]byte, error) {
+	b, err := ParseHex(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.Name, err)
+	}
+	if len(b) != r.Len() {
+		return nil, fmt.Errorf("%s: want %d bytes, got %d", r.Name, r.Len(), len(b))
+	}
+	return b, nil
+}
+
+func printable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Layout is the region table of an image, ordered by offset.
+type Layout []Region
+
+// Find returns the region named name.
+func (l Layout) Find(name string) (Region, bool) {
+	for _, r := range l {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Region{}, false
+}
+
+// At returns the first region containing pos.
+func (l Layout) At(pos int) (Region, bool) {
+	for _, r := range l {
+		if r.Contains(pos) {
+			return r, true
+		}
+	}
+	return Region{}, false
+}
+
+// Mirror returns the other bank of a region named "... #1" or "... #2".
+// Regions whose counterpart has another length, or sits on the same bytes,
+// have no mirror.
+func (l Layout) Mirror(r Region) (Region, bool) {
+	var other string
+	switch {
+	case strings.Contains(r.Name, "#1"):
+		other = strings.Replace(r.Name, "#1", "#2", 1)
+	case strings.Contains(r.Name, "#2"):
+		other = strings.Replace(r.Name, "#2", "#1", 1)
+	default:
+		return Region{}, false
+	}
+	m, ok := l.Find(other)
+	if !ok || m.Len() != r.Len() || m.Start == r.Start {
+		return Region{}, false
+	}
+	return m, true
+}
+
+// ErrRegion is returned for regions that don't fit the image.
+var ErrRegion = errors.New("region outside image")
+
+func fits(img []byte, s Span) bool {
+	return s.Start >= 0 && s.End < len(img) && s.Start <= s.End
+}
+
+// Set writes value to region r of img, and to its mirror bank if it has one.
+// It returns the spans that changed.
+func (l Layout) Set(img []byte, r Region, value []byte) ([]Span, error) {
+	if !fits(img, r.Span) {
+		return nil, fmt.Errorf("%s: %w", r.Name, ErrRegion)
+	}
+	if len(value) != r.Len() {
+		return nil, fmt.Errorf("%s: want %d bytes, got %d", r.Name, r.Len(), len(value))
+	}
+	changed := []Span{r.Span}
+	copy(img[r.Start:], value)
+	if m, ok := l.Mirror(r); ok && fits(img, m.Span) {
+		copy(img[m.Start:], value)
+		changed = append(changed, m.Span)
+	}
+	return changed, nil
+}
+
+// FixChecksums recomputes every CRC region covering one of the changed spans
+// and returns the regions it rewrote.
+func (l Layout) FixChecksums(img []byte, changed []Span) ([]Region, error) {
+	var fixed []Region
+	for _, r := range l {
+		if r.Checksum == nil || r.Len() != 2 {
+			continue
+		}
+		if !fits(img, r.Span) || !fits(img, *r.Checksum) {
+			return fixed, fmt.Errorf("%s: %w", r.Name, ErrRegion)
+		}
+		for _, c := range changed {
+			if r.Checksum.Overlaps(c) {
+				binary.BigEndian.PutUint16(img[r.Start:], CRC16(img[r.Checksum.Start:r.Checksum.End+1]))
+				fixed = append(fixed, r)
+				break
+			}
+		}
+	}
+	return fixed, nil
+}
+
+// ErrChecksum is returned by Verify for CRC regions that don't match.
+var ErrChecksum = errors.New("checksum mismatch")
+
+// Verify checks the CRC region r against the bytes it covers.
+func (l Layout) Verify(img []byte, r Region) (stored, computed uint16, err error) {
+	if r.Checksum == nil || r.Len() != 2 {
+		return 0, 0, fmt.Errorf("%s is not a checksum", r.Name)
+	}
+	if !fits(img, r.Span) || !fits(img, *r.Checksum) {
+		return 0, 0, fmt.Errorf("%s: %w", r.Name, ErrRegion)
+	}
+	stored = binary.BigEndian.Uint16(img[r.Start:])
+	computed = CRC16(img[r.Checksum.Start : r.Checksum.End+1])
+	if stored != computed {
+		return stored, computed, fmt.Errorf("%s: %w", r.Name, ErrChecksum)
+	}
+	return stored, computed, nil
+}
+
+// Check is the outcome of verifying one CRC region.
+type Check struct {
+	Region
+	Stored   uint16
+	Computed uint16
+	// Err is nil when the checksum matches. It wraps ErrChecksum on a
+	// mismatch and ErrRegion when the region doesn't fit the image.
+	Err error
+}
+
+// OK reports whether the stored checksum matches.
+func (c Check) OK() bool {
+	return c.Err == nil
+}
+
+// Checks verifies every CRC region of img, in layout order.
+func (l Layout) Checks(img []byte) []Check {
+	var checks []Check
+	for _, r := range l {
+		if r.Checksum == nil || r.Len() != 2 {
+			continue
+		}
+		stored, computed, err := l.Verify(img, r)
+		checks = append(checks, Check{Region: r, Stored: stored, Computed: computed, Err: err})
+	}
+	return checks
+}
+
+// RepairChecksums rewrites the CRC regions of img that don't match the data
+// they cover and returns them. Matching checksums are left untouched.
+func (l Layout) RepairChecksums(img []byte) ([]Region, error) {
+	var fixed []Region
+	for _, c := range l.Checks(img) {
+		switch {
+		case c.OK():
+		case errors.Is(c.Err, ErrChecksum):
+			binary.BigEndian.PutUint16(img[c.Start:], c.Computed)
+			fixed = append(fixed, c.Region)
+		default:
+			return fixed, c.Err
+		}
+	}
+	return fixed, nil
+}
+
+// CRC16 is the CRC-16/X-25 the CIM stores after its data blocks: reflected
+// CCITT polynomial, initial value and final XOR 0xFFFF.
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0x8408
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
@@ -0,0 +1,163 @@
+package eeprom
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var testLayout = Layout{
+	{Name: "PartNo", Span: Span{0x00, 0x03}, Kind: Uint},
+	{Name: "Rev", Span: Span{0x04, 0x05}, Kind: ASCII},
+	{Name: "Data #1", Span: Span{0x10, 0x13}},
+	{Name: "Data #1 CRC", Span: Span{0x14, 0x15}, Checksum: &Span{0x10, 0x13}},
+	{Name: "Data #2", Span: Span{0x16, 0x19}},
+	{Name: "Data #2 CRC", Span: Span{0x1a, 0x1b}, Checksum: &Span{0x16, 0x19}},
+	{Name: "Same #1", Span: Span{0x20, 0x21}},
+	{Name: "Same #2", Span: Span{0x20, 0x21}},
+}
+
+func TestCRC16(t *testing.T) {
+	// CRC-16/X-25 check value.
+	if got := CRC16([]byte("123456789")); got != 0x906E {
+		t.Fatalf("CRC16(123456789) = %04X, want 906E", got)
+	}
+}
+
+func TestFormatParse(t *testing.T) {
+	for _, tc := range []struct {
+		r    Region
+		b    []byte
+		want string
+	}{
+		{testLayout[0], []byte{0x00, 0xbc, 0x61, 0x4e}, "12345678"},
+		{testLayout[1], []byte("AB"), "AB"},
+		{testLayout[2], []byte{0xde, 0xad, 0xbe, 0xef}, "DEADBEEF"},
+		{testLayout[3], []byte{0x90, 0x6e}, "906E"},
+	} {
+		if got := tc.r.Format(tc.b); got != tc.want {
+			t.Errorf("%s: Format = %q, want %q", tc.r.Name, got, tc.want)
+		}
+		b, err := tc.r.Parse(tc.want)
+		if err != nil {
+			t.Errorf("%s: Parse: %v", tc.r.Name, err)
+			continue
+		}
+		if !bytes.Equal(b, tc.b) {
+			t.Errorf("%s: Parse = %X, want %X", tc.r.Name, b, tc.b)
+		}
+	}
+
+	// Unprintable text falls back to hex.
+	if got := testLayout[1].Format([]byte{0xff, 0x00}); got != "FF00" {
+		t.Errorf("Format unprintable = %q", got)
+	}
+	for _, bad := range []struct {
+		r Region
+		s string
+	}{
+		{testLayout[0], "4294967296"},
+		{testLayout[0], "12ab"},
+		{testLayout[1], "ABC"},
+		{testLayout[1], "A\x01"},
+		{testLayout[2], "DEADBE"},
+		{testLayout[2], "DEADBEEG"},
+	} {
+		if _, err := bad.r.Parse(bad.s); err == nil {
+			t.Errorf("%s: Parse(%q) succeeded", bad.r.Name, bad.s)
+		}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	m, ok := testLayout.Mirror(testLayout[2])
+	if !ok || m.Name != "Data #2" {
+		t.Fatalf("Mirror(Data #1) = %v, %v", m, ok)
+	}
+	if _, ok := testLayout.Mirror(testLayout[0]); ok {
+		t.Error("PartNo has no mirror")
+	}
+	if _, ok := testLayout.Mirror(testLayout[6]); ok {
+		t.Error("regions on the same bytes are not mirrors")
+	}
+}
+
+func TestSetAndFixChecksums(t *testing.T) {
+	img := make([]byte, Size)
+	changed, err := testLayout.Set(img, testLayout[4], []byte{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 || !bytes.Equal(img[0x10:0x14], img[0x16:0x1a]) {
+		t.Fatalf("mirror not written: changed %v, img %X", changed, img[0x10:0x1c])
+	}
+
+	for _, r := range testLayout {
+		if r.Checksum == nil {
+			continue
+		}
+		if _, _, err := testLayout.Verify(img, r); !errors.Is(err, ErrChecksum) {
+			t.Errorf("%s: Verify before fix = %v", r.Name, err)
+		}
+	}
+
+	fixed, err := testLayout.FixChecksums(img, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixed) != 2 {
+		t.Fatalf("fixed %v, want both CRCs", fixed)
+	}
+	for _, r := range fixed {
+		if _, _, err := testLayout.Verify(img, r); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := testLayout.Set(img, testLayout[0], []byte{1}); err == nil {
+		t.Error("Set accepted a short value")
+	}
+	if _, err := testLayout.Set(img[:2], testLayout[0], []byte{1, 2, 3, 4}); !errors.Is(err, ErrRegion) {
+		t.Errorf("Set short image = %v", err)
+	}
+}
+
+func TestChecksAndRepair(t *testing.T) {
+	img := make([]byte, Size)
+	if _, err := testLayout.FixChecksums(img, []Span{{0, Size - 1}}); err != nil {
+		t.Fatal(err)
+	}
+	img[0x16] = 0xaa
+	good := bytes.Clone(img[0x14:0x16])
+
+	checks := testLayout.Checks(img)
+	if len(checks) != 2 {
+		t.Fatalf("got %d checks, want 2", len(checks))
+	}
+	if !checks[0].OK() || checks[1].OK() || !errors.Is(checks[1].Err, ErrChecksum) {
+		t.Fatalf("checks = %+v", checks)
+	}
+	if checks[1].Stored == checks[1].Computed {
+		t.Errorf("failing check has stored == computed %04X", checks[1].Stored)
+	}
+
+	fixed, err := testLayout.RepairChecksums(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixed) != 1 || fixed[0].Name != "Data #2 CRC" {
+		t.Fatalf("repaired %v, want only Data #2 CRC", fixed)
+	}
+	if !bytes.Equal(img[0x14:0x16], good) {
+		t.Error("passing checksum was rewritten")
+	}
+	for _, c := range testLayout.Checks(img) {
+		if !c.OK() {
+			t.Errorf("%s still fails after repair: %v", c.Name, c.Err)
+		}
+	}
+
+	if _, err := testLayout.RepairChecksums(img[:0x15]); !errors.Is(err, ErrRegion) {
+		t.Errorf("RepairChecksums short image = %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package eeprom
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FormatVersion is the layout file format this package reads.
+const FormatVersion = 1
+
+// Definition is a region map for one kind of EEPROM, as loaded from a
+// layout file.
+type Definition struct {
+	Name        string
+	Description string
+	Size        int
+	Regions     Layout
+	// Source is the file the definition was loaded from, empty when built in.
+	Source string
+	// Warnings are validation findings that don't stop the layout from
+	// being used, like bytes no region covers.
+	Warnings []string
+}
+
+// Layout files are JSON. Offsets are hex strings so they can't be mistaken
+// for decimal:
+//
+//	{
+//	  "format": 1,
+//	  "name": "Saab CIM",
+//	  "size": 512,
+//	  "regions": [
+//	    {"name": "PIN Data #1", "start": "0x0AF", "end": "0x0B2", "color": "#AABBCC"},
+//	    {"name": "PIN CRC #1", "start": "0x0B7", "end": "0x0B8", "crc": {"start": "0x0AF", "end": "0x0B6"}}
+//	  ]
+//	}
+type layoutFile struct {
+	Format      int          `json:"format"`
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Size        int          `json:"size"`
+	Regions     []fileRegion `json:"regions"`
+}
+
+type fileRegion struct {
+	Name  string    `json:"name"`
+	Start offset    `json:"start"`
+	End   offset    `json:"end"`
+	Kind  string    `json:"kind,omitempty"`
+	CRC   *fileSpan `json:"crc,omitempty"`
+	Color string    `json:"color,omitempty"`
+}
+
+type fileSpan struct {
+	Start offset `json:"start"`
+	End   offset `json:"end"`
+}
+
+type offset int
+
+func (o *offset) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil || !strings.HasPrefix(strings.ToLower(s), "0x") {
+		return fmt.Errorf("offset %s: want a hex string like \"0x1A0\"", b)
+	}
+	v, err := strconv.ParseUint(s[2:], 16, 16)
+	if err != nil {
+		return fmt.Errorf("offset %q: %w", s, err)
+	}
+	*o = offset(v)
+	return nil
+}
+
+// ParseKind returns the kind named s, as used in layout files.
+func ParseKind(s string) (Kind, error) {
+	switch s {
+	case "", "hex":
+		return Hex, nil
+	case "uint":
+		return Uint, nil
+	case "ascii":
+		return ASCII, nil
+	}
+	return Hex, fmt.Errorf("unknown kind %q", s)
+}
+
+func parseColor(s string) (color.RGBA, error) {
+	if s == "" {
+		return color.RGBA{R: 255, G: 255, B: 255, A: 255}, nil
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(s) != 7 || s[0] != '#' {
+		return color.RGBA{}, fmt.Errorf("color %q: want #RRGGBB", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// Parse reads and validates a layout file.
+func Parse(data []byte) (*Definition, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var f layoutFile
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("parse layout: %w", err)
+	}
+	if f.Format != FormatVersion {
+		return nil, fmt.Errorf("layout %q: format %d not supported, want %d", f.Name, f.Format, FormatVersion)
+	}
+
+	d := &Definition{Name: f.Name, Description: f.Description, Size: f.Size}
+	for _, fr := range f.Regions {
+		kind, err := ParseKind(fr.Kind)
+		if err != nil {
+			return nil, fmt.Errorf("layout %q: %s: %w", f.Name, fr.Name, err)
+		}
+		c, err := parseColor(fr.Color)
+		if err != nil {
+			return nil, fmt.Errorf("layout %q: %s: %w", f.Name, fr.Name, err)
+		}
+		r := Region{
+			Name:  fr.Name,
+			Span:  Span{Start: int(fr.Start), End: int(fr.End)},
+			Kind:  kind,
+			Color: c,
+		}
+		if fr.CRC != nil {
+			r.Checksum = &Span{Start: int(fr.CRC.Start), End: int(fr.CRC.End)}
+		}
+		d.Regions = append(d.Regions, r)
+	}
+
+	warnings, err := d.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("layout %q: %w", f.Name, err)
+	}
+	d.Warnings = warnings
+	return d, nil
+}
+
+// Load reads a layout file from disk.
+func Load(filename string) (*Definition, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	d, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	d.Source = filename
+	return d, nil
+}
+
+// Validate checks the regions against each other and the EEPROM size.
+// Overlapping, misplaced or duplicate regions are errors. Bytes no region
+// covers are returned as warnings.
+func (d *Definition) Validate() (warnings []string, err error) {
+	var errs []error
+	if d.Size <= 0 {
+		errs = append(errs, fmt.Errorf("size %d", d.Size))
+	}
+	if len(d.Regions) == 0 {
+		errs = append(errs, errors.New("no regions"))
+	}
+
+	names := make(map[string]bool)
+	for _, r := range d.Regions {
+		switch {
+		case r.Name == "":
+			errs = append(errs, fmt.Errorf("region at 0x%03X has no name", r.Start))
+		case names[r.Name]:
+			errs = append(errs, fmt.Errorf("duplicate region %q", r.Name))
+		}
+		names[r.Name] = true
+		if r.End < r.Start || r.Start < 0 || r.End >= d.Size {
+			errs = append(errs, fmt.Errorf("%s: 0x%03X-0x%03X outside 0x000-0x%03X", r.Name, r.Start, r.End, d.Size-1))
+		}
+		if c := r.Checksum; c != nil {
+			switch {
+			case r.Len() != 2:
+				errs = append(errs, fmt.Errorf("%s: checksum regions must be 2 bytes", r.Name))
+			case c.End < c.Start || c.Start < 0 || c.End >= d.Size:
+				errs = append(errs, fmt.Errorf("%s: checksum span 0x%03X-0x%03X outside the EEPROM", r.Name, c.Start, c.End))
+			case c.Overlaps(r.Span):
+				errs = append(errs, fmt.Errorf("%s: checksum covers itself", r.Name))
+			}
+		}
+	}
+
+	sorted := slices.Clone(d.Regions)
+	slices.SortStableFunc(sorted, func(a, b Region) int { return a.Start - b.Start })
+	// next is one past the furthest end so far, reached by region last
+	next := 0
+	var last Region
+	for i, r := range sorted {
+		if i > 0 && r.Start < next {
+			errs = append(errs, fmt.Errorf("%s overlaps %s at 0x%03X", r.Name, last.Name, r.Start))
+		}
+		if r.Start > next {
+			warnings = append(warnings, fmt.Sprintf("0x%03X-0x%03X not covered by any region", next, r.Start-1))
+		}
+		if r.End+1 > next {
+			next = r.End + 1
+			last = r
+		}
+	}
+	if d.Size > 0 && next < d.Size && len(sorted) > 0 {
+		warnings = append(warnings, fmt.Sprintf("0x%03X-0x%03X not covered by any region", next, d.Size-1))
+	}
+	return warnings, errors.Join(errs...)
+}
+
+//go:embed layouts/*.json
+var builtinFS embed.FS
+
+// Builtin returns the layouts shipped with eep. They are shared, callers
+// must not modify them.
+func Builtin() []*Definition {
+	return slices.Clone(builtin())
+}
+
+var builtin = sync.OnceValue(func() []*Definition {
+	entries, err := builtinFS.ReadDir("layouts")
+	if err != nil {
+		panic(err)
+	}
+	var defs []*Definition
+	for _, e := range entries {
+		data, err := builtinFS.ReadFile(path.Join("layouts", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		d, err := Parse(data)
+		if err != nil {
+			panic(fmt.Sprintf("built in layout %s: %v", e.Name(), err))
+		}
+		defs = append(defs, d)
+	}
+	return defs
+})
+
+// ForSize returns the built in layout for an EEPROM of size bytes, or nil.
+func ForSize(size int) *Definition {
+	for _, d := range builtin() {
+		if d.Size == size {
+			return d
+		}
+	}
+	return nil
+}
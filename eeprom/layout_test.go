@@ -0,0 +1,156 @@
+package eeprom
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltin(t *testing.T) {
+	defs := Builtin()
+	if len(defs) != 2 {
+		t.Fatalf("got %d built in layouts, want CIM and MIU", len(defs))
+	}
+	cim := ForSize(512)
+	if cim == nil || cim.Name != "Saab CIM" {
+		t.Fatalf("ForSize(512) = %v", cim)
+	}
+	if len(cim.Warnings) != 0 {
+		t.Errorf("CIM layout warnings: %v", cim.Warnings)
+	}
+	if miu := ForSize(128); miu == nil || miu.Name != "Saab MIU" {
+		t.Errorf("ForSize(128) = %v", miu)
+	}
+	if ForSize(1024) != nil {
+		t.Error("ForSize(1024) found a layout")
+	}
+
+	r, ok := cim.Regions.Find("Unknown Data 3 #1 CRC")
+	if !ok || r.Start != 0x81 || r.End != 0x82 {
+		t.Errorf("Unknown Data 3 #1 CRC = %v", r)
+	}
+	for _, r := range cim.Regions {
+		if strings.Contains(r.Name, "Unknwon") || strings.Contains(r.Name, "Unnown") {
+			t.Errorf("misspelled region %q", r.Name)
+		}
+		if r.Checksum != nil {
+			if _, _, err := cim.Regions.Verify(make([]byte, cim.Size), r); err == nil {
+				// An all zero image can't match a CRC-16/X-25
+				t.Errorf("%s verified on an empty image", r.Name)
+			}
+		}
+	}
+	if m, ok := cim.Regions.Mirror(cim.Regions[0]); ok {
+		t.Errorf("%s mirrored by %s", cim.Regions[0].Name, m.Name)
+	}
+	if m, ok := cim.Regions.Mirror(mustFind(t, cim, "Unknown Data 2 #1")); !ok || m.Start != 0x1c6 {
+		t.Errorf("Unknown Data 2 #1 mirror = %v, %v", m, ok)
+	}
+}
+
+func mustFind(t *testing.T, d *Definition, name string) Region {
+	t.Helper()
+	r, ok := d.Regions.Find(name)
+	if !ok {
+		t.Fatalf("no region %q", name)
+	}
+	return r
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		regions string
+		size    string
+		format  string
+		want    string
+	}{
+		"overlap": {
+			regions: `{"name": "A", "start": "0x00", "end": "0x04"}, {"name": "B", "start": "0x04", "end": "0x07"}`,
+			want:    "B overlaps A at 0x004",
+		},
+		"nested overlap": {
+			regions: `{"name": "A", "start": "0x00", "end": "0x07"}, {"name": "B", "start": "0x02", "end": "0x03"}, {"name": "C", "start": "0x05", "end": "0x06"}`,
+			want:    "C overlaps A at 0x005",
+		},
+		"decimal offset": {
+			regions: `{"name": "A", "start": 81, "end": "0x07"}`,
+			want:    "want a hex string",
+		},
+		"duplicate": {
+			regions: `{"name": "A", "start": "0x00", "end": "0x03"}, {"name": "A", "start": "0x04", "end": "0x07"}`,
+			want:    `duplicate region "A"`,
+		},
+		"outside": {
+			regions: `{"name": "A", "start": "0x00", "end": "0x08"}`,
+			want:    "outside 0x000-0x007",
+		},
+		"crc length": {
+			regions: `{"name": "A", "start": "0x00", "end": "0x04"}, {"name": "A CRC", "start": "0x05", "end": "0x07", "crc": {"start": "0x00", "end": "0x04"}}`,
+			want:    "checksum regions must be 2 bytes",
+		},
+		"crc self": {
+			regions: `{"name": "A", "start": "0x00", "end": "0x05"}, {"name": "A CRC", "start": "0x06", "end": "0x07", "crc": {"start": "0x00", "end": "0x06"}}`,
+			want:    "checksum covers itself",
+		},
+		"kind": {
+			regions: `{"name": "A", "start": "0x00", "end": "0x07", "kind": "float"}`,
+			want:    `unknown kind "float"`,
+		},
+		"color": {
+			regions: `{"name": "A", "start": "0x00", "end": "0x07", "color": "red"}`,
+			want:    "want #RRGGBB",
+		},
+		"format": {
+			regions: `{"name": "A", "start": "0x00", "end": "0x07"}`,
+			format:  "2",
+			want:    "format 2 not supported",
+		},
+		"unknown field": {
+			regions: `{"name": "A", "start": "0x00", "end": "0x07", "length": 8}`,
+			want:    "unknown field",
+		},
+	} {
+		format := tc.format
+		if format == "" {
+			format = "1"
+		}
+		doc := `{"format": ` + format + `, "name": "test", "size": 8, "regions": [` + tc.regions + `]}`
+		_, err := Parse([]byte(doc))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, tc.want)
+		}
+	}
+}
+
+func TestLoadGaps(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "module.json")
+	doc := `{
+		"format": 1,
+		"name": "module",
+		"size": 16,
+		"regions": [
+			{"name": "Serial", "start": "0x02", "end": "0x05", "kind": "ascii", "color": "#A01222"},
+			{"name": "Serial CRC", "start": "0x06", "end": "0x07", "crc": {"start": "0x02", "end": "0x05"}},
+			{"name": "Tail", "start": "0x0A", "end": "0x0B"}
+		]
+	}`
+	if err := os.WriteFile(filename, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Source != filename || len(d.Regions) != 3 || d.Regions[0].Kind != ASCII || d.Regions[0].Color.R != 0xA0 {
+		t.Errorf("loaded %+v", d)
+	}
+	want := []string{
+		"0x000-0x001 not covered by any region",
+		"0x008-0x009 not covered by any region",
+		"0x00C-0x00F not covered by any region",
+	}
+	if strings.Join(d.Warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("warnings = %q, want %q", d.Warnings, want)
+	}
+}
@@ -0,0 +1,146 @@
+package eeprom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MirrorState is the consistency of a pair of mirrored banks.
+type MirrorState int
+
+const (
+	// MirrorOK is two valid, identical banks.
+	MirrorOK MirrorState = iota
+	// MirrorDiffer is two valid banks holding different data. There is no
+	// telling which one is right.
+	MirrorDiffer
+	// MirrorBad1 is a corrupt bank #1 next to a valid bank #2.
+	MirrorBad1
+	// MirrorBad2 is a corrupt bank #2 next to a valid bank #1.
+	MirrorBad2
+	// MirrorBadBoth is two corrupt banks.
+	MirrorBadBoth
+)
+
+func (s MirrorState) String() string {
+	switch s {
+	case MirrorOK:
+		return "consistent"
+	case MirrorDiffer:
+		return "both valid, data differs"
+	case MirrorBad1:
+		return "bank #1 corrupt"
+	case MirrorBad2:
+		return "bank #2 corrupt"
+	default:
+		return "both banks corrupt"
+	}
+}
+
+// MirrorPair is a CRC protected bank stored twice, as "... #1" and "... #2".
+// A bank is the span its CRC covers followed by the CRC.
+type MirrorPair struct {
+	Name  string
+	Bank1 Check
+	Bank2 Check
+	State MirrorState
+}
+
+// Spans returns the data and CRC spans of bank n, 1 or 2.
+func (p MirrorPair) Spans(n int) []Span {
+	c := p.Bank1
+	if n == 2 {
+		c = p.Bank2
+	}
+	return []Span{*c.Checksum, c.Span}
+}
+
+// Repairable reports whether one bank can be rebuilt from the other.
+func (p MirrorPair) Repairable() bool {
+	return p.State == MirrorBad1 || p.State == MirrorBad2
+}
+
+// ErrMirror is returned when a pair has no valid bank to rebuild from.
+var ErrMirror = errors.New("no single valid bank to rebuild from")
+
+// Mirrors compares every pair of mirrored CRC banks of img.
+func (l Layout) Mirrors(img []byte) []MirrorPair {
+	checks := l.Checks(img)
+	var pairs []MirrorPair
+	for _, c1 := range checks {
+		if !strings.Contains(c1.Name, "#1") {
+			continue
+		}
+		m, ok := l.Mirror(c1.Region)
+		if !ok || m.Checksum == nil || m.Checksum.Len() != c1.Checksum.Len() {
+			continue
+		}
+		for _, c2 := range checks {
+			if c2.Name == m.Name {
+				pairs = append(pairs, newMirrorPair(img, c1, c2))
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+func newMirrorPair(img []byte, c1, c2 Check) MirrorPair {
+	p := MirrorPair{
+		Name:  strings.Join(strings.Fields(strings.NewReplacer("#1", "", "CRC", "").Replace(c1.Name)), " "),
+		Bank1: c1,
+		Bank2: c2,
+	}
+	switch {
+	case c1.OK() && c2.OK():
+		p.State = MirrorOK
+		for i, s := range p.Spans(1) {
+			if !bytes.Equal(img[s.Start:s.End+1], img[p.Spans(2)[i].Start:p.Spans(2)[i].End+1]) {
+				p.State = MirrorDiffer
+			}
+		}
+	case c2.OK() && errors.Is(c1.Err, ErrChecksum):
+		p.State = MirrorBad1
+	case c1.OK() && errors.Is(c2.Err, ErrChecksum):
+		p.State = MirrorBad2
+	default:
+		p.State = MirrorBadBoth
+	}
+	return p
+}
+
+// Rebuild copies the valid bank of p over the corrupt one and returns the
+// spans it wrote.
+func (p MirrorPair) Rebuild(img []byte) ([]Span, error) {
+	from, to := 1, 2
+	switch p.State {
+	case MirrorBad1:
+		from, to = 2, 1
+	case MirrorBad2:
+	default:
+		return nil, fmt.Errorf("%s: %s: %w", p.Name, p.State, ErrMirror)
+	}
+	src, dst := p.Spans(from), p.Spans(to)
+	for i := range src {
+		if !fits(img, src[i]) || !fits(img, dst[i]) {
+			return nil, fmt.Errorf("%s: %w", p.Name, ErrRegion)
+		}
+		copy(img[dst[i].Start:dst[i].End+1], img[src[i].Start:src[i].End+1])
+	}
+	return dst, nil
+}
+
+// RebuildMirrors returns a copy of img with the corrupt bank of each of
+// pairs rebuilt. Any other pair, like one corrupt on both banks, is left as
+// it is, so the result may still fail its checks.
+func RebuildMirrors(img []byte, pairs []MirrorPair) ([]byte, error) {
+	out := bytes.Clone(img)
+	for _, p := range pairs {
+		if _, err := p.Rebuild(out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package eeprom
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMirrors(t *testing.T) {
+	img := make([]byte, Size)
+	changed, err := testLayout.Set(img, testLayout[2], []byte{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testLayout.FixChecksums(img, changed); err != nil {
+		t.Fatal(err)
+	}
+
+	pairs := testLayout.Mirrors(img)
+	if len(pairs) != 1 || pairs[0].Name != "Data" || pairs[0].State != MirrorOK {
+		t.Fatalf("pairs = %+v", pairs)
+	}
+	if _, err := pairs[0].Rebuild(img); !errors.Is(err, ErrMirror) {
+		t.Errorf("Rebuild consistent pair = %v", err)
+	}
+
+	good := bytes.Clone(img)
+	img[0x17] ^= 0xff // corrupt bank #2
+	p := testLayout.Mirrors(img)[0]
+	if p.State != MirrorBad2 || !p.Repairable() {
+		t.Fatalf("state = %v", p.State)
+	}
+	spans, err := p.Rebuild(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 2 || spans[0] != (Span{0x16, 0x19}) || spans[1] != (Span{0x1a, 0x1b}) {
+		t.Errorf("rebuilt spans = %v", spans)
+	}
+	if !bytes.Equal(img, good) {
+		t.Errorf("rebuilt image differs: %X", img[0x10:0x1c])
+	}
+
+	img[0x12] ^= 0xff // corrupt bank #1
+	if p := testLayout.Mirrors(img)[0]; p.State != MirrorBad1 {
+		t.Errorf("state = %v, want %v", p.State, MirrorBad1)
+	}
+	img[0x17] ^= 0xff
+	if p := testLayout.Mirrors(img)[0]; p.State != MirrorBadBoth || p.Repairable() {
+		t.Errorf("state = %v, want %v", p.State, MirrorBadBoth)
+	}
+
+	// Both valid but holding different data
+	img = bytes.Clone(good)
+	img[0x16] = 0xaa
+	if _, err := testLayout.FixChecksums(img, []Span{{0x16, 0x16}}); err != nil {
+		t.Fatal(err)
+	}
+	if p := testLayout.Mirrors(img)[0]; p.State != MirrorDiffer {
+		t.Errorf("state = %v, want %v", p.State, MirrorDiffer)
+	}
+}
+
+func TestRebuildMirrors(t *testing.T) {
+	l := append(Layout{
+		{Name: "Key #1", Span: Span{0x30, 0x31}},
+		{Name: "Key #1 CRC", Span: Span{0x32, 0x33}, Checksum: &Span{0x30, 0x31}},
+		{Name: "Key #2", Span: Span{0x34, 0x35}},
+		{Name: "Key #2 CRC", Span: Span{0x36, 0x37}, Checksum: &Span{0x34, 0x35}},
+	}, testLayout...)
+	img := make([]byte, Size)
+	for _, r := range []string{"Data #1", "Data #2", "Key #1", "Key #2"} {
+		reg, _ := l.Find(r)
+		changed, err := l.Set(img, reg, bytes.Repeat([]byte{0x5a}, reg.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.FixChecksums(img, changed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	good := bytes.Clone(img)
+
+	img[0x17] ^= 0xff // Data bank #2, repairable
+	img[0x30] ^= 0xff // both Key banks
+	img[0x34] ^= 0xff
+
+	var repairable []MirrorPair
+	for _, p := range l.Mirrors(img) {
+		switch p.Name {
+		case "Data":
+			if p.State != MirrorBad2 {
+				t.Fatalf("Data state = %v", p.State)
+			}
+		case "Key":
+			if p.State != MirrorBadBoth {
+				t.Fatalf("Key state = %v", p.State)
+			}
+		}
+		if p.Repairable() {
+			repairable = append(repairable, p)
+		}
+	}
+	if len(repairable) != 1 {
+		t.Fatalf("repairable = %+v", repairable)
+	}
+
+	before := bytes.Clone(img)
+	saved, err := RebuildMirrors(img, repairable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img, before) {
+		t.Error("RebuildMirrors modified its input")
+	}
+	// The rebuild is kept even though the image still fails its checks
+	if !bytes.Equal(saved[0x10:0x1c], good[0x10:0x1c]) {
+		t.Errorf("Data banks = %X, want %X", saved[0x10:0x1c], good[0x10:0x1c])
+	}
+	if !bytes.Equal(saved[0x30:0x38], before[0x30:0x38]) {
+		t.Errorf("Key banks changed: %X", saved[0x30:0x38])
+	}
+	failed := 0
+	for _, c := range l.Checks(saved) {
+		if !c.OK() {
+			failed++
+		}
+	}
+	if failed != 2 {
+		t.Errorf("%d failed checks after rebuild, want the 2 Key banks", failed)
+	}
+
+	if _, err := RebuildMirrors(img, l.Mirrors(img)); !errors.Is(err, ErrMirror) {
+		t.Errorf("RebuildMirrors with unrepairable pairs = %v", err)
+	}
+}
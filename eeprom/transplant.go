@@ -0,0 +1,40 @@
+package eeprom
+
+import "fmt"
+
+// Group is a set of regions that are copied together, like every copy of
+// the PIN.
+type Group struct {
+	Name    string
+	Regions []string
+}
+
+// Transplant copies the regions named names from src to dst and recomputes
+// the checksums covering them. It returns the spans it copied and the CRC
+// regions it rewrote. dst is left untouched on error.
+func (l Layout) Transplant(dst, src []byte, names []string) ([]Span, []Region, error) {
+	var regions []Region
+	for _, name := range names {
+		r, ok := l.Find(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("layout has no region %q", name)
+		}
+		if !fits(dst, r.Span) || !fits(src, r.Span) {
+			return nil, nil, fmt.Errorf("%s: %w", r.Name, ErrRegion)
+		}
+		regions = append(regions, r)
+	}
+
+	out := append([]byte(nil), dst...)
+	var changed []Span
+	for _, r := range regions {
+		copy(out[r.Start:r.End+1], src[r.Start:r.End+1])
+		changed = append(changed, r.Span)
+	}
+	fixed, err := l.FixChecksums(out, changed)
+	if err != nil {
+		return nil, nil, err
+	}
+	copy(dst, out)
+	return changed, fixed, nil
+}
@@ -0,0 +1,42 @@
+package eeprom
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTransplant(t *testing.T) {
+	src := make([]byte, Size)
+	dst := make([]byte, Size)
+	for i := range src {
+		src[i] = byte(i)
+		dst[i] = 0xee
+	}
+
+	changed, fixed, err := testLayout.Transplant(dst, src, []string{"Data #1", "Data #2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 || len(fixed) != 2 {
+		t.Fatalf("changed %v, fixed %v", changed, fixed)
+	}
+	if !bytes.Equal(dst[0x10:0x14], src[0x10:0x14]) || !bytes.Equal(dst[0x16:0x1a], src[0x16:0x1a]) {
+		t.Errorf("regions not copied: %X", dst[0x10:0x1c])
+	}
+	if dst[0x00] != 0xee || dst[0x04] != 0xee {
+		t.Error("unselected regions were copied")
+	}
+	for _, c := range testLayout.Checks(dst) {
+		if !c.OK() {
+			t.Errorf("%s: %v", c.Name, c.Err)
+		}
+	}
+
+	before := bytes.Clone(dst)
+	if _, _, err := testLayout.Transplant(dst, src, []string{"PartNo", "Nope"}); err == nil {
+		t.Error("Transplant accepted an unknown region")
+	}
+	if !bytes.Equal(dst, before) {
+		t.Error("failed Transplant modified dst")
+	}
+}
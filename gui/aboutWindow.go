@@ -1,16 +1,21 @@
 package gui
 
 import (
+	"context"
+	"fmt"
 	"net/url"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/widget"
 	"github.com/roffe/eep/assets"
+	"github.com/roffe/eep/update"
+	"golang.org/x/mod/semver"
 )
 
-func aboutView(aw fyne.App) fyne.CanvasObject {
+func aboutView(e *EEPGui) fyne.CanvasObject {
 	img := &canvas.Image{
 		ScaleMode: canvas.ImageScaleFastest,
 		FillMode:  canvas.ImageFillOriginal,
@@ -20,11 +25,11 @@ func aboutView(aw fyne.App) fyne.CanvasObject {
 	}
 	img.SetMinSize(fyne.NewSize(400, 400))
 
-	return container.NewBorder(
+	logo := container.NewBorder(
 		nil,
 		widget.NewButton("Visit homepage", func() {
 			u, _ := url.Parse("https://roffe.nu")
-			aw.OpenURL(u)
+			e.OpenURL(u)
 		}),
 		nil,
 		nil,
@@ -36,4 +41,69 @@ func aboutView(aw fyne.App) fyne.CanvasObject {
 			},
 		),
 	)
+
+	split := container.NewHSplit(logo, newVersionsView(e))
+	split.Offset = 0.4
+	return split
+}
+
+// newVersionsView lists the releases on the selected update channel with
+// their notes. It reloads when the channel changes.
+func newVersionsView(e *EEPGui) fyne.CanvasObject {
+	title := &widget.Label{TextStyle: fyne.TextStyle{Bold: true}}
+	status := widget.NewLabel("")
+	view := container.NewStack(status)
+
+	load := func() {
+		ch := e.channel()
+		title.SetText(fmt.Sprintf("Available versions (%s channel), running %s", ch, VERSION))
+		status.SetText("Loading releases ...")
+		view.Objects = []fyne.CanvasObject{status}
+		view.Refresh()
+		go func() {
+			releases, err := e.updateClient().Releases(context.Background())
+			fyne.Do(func() {
+				if err != nil {
+					status.SetText(fmt.Sprintf("Could not load releases: %v", err))
+					return
+				}
+				offered := ch.Filter(releases)
+				if len(offered) == 0 {
+					status.SetText("No releases on this channel")
+					return
+				}
+				view.Objects = []fyne.CanvasObject{newVersionList(offered)}
+				view.Refresh()
+			})
+		}()
+	}
+	e.updateChannel.AddListener(binding.NewDataListener(load))
+
+	return container.NewBorder(title, nil, nil, nil, view)
+}
+
+// newVersionList shows one collapsible item per release, the newest one
+// opened.
+func newVersionList(releases []*update.Release) fyne.CanvasObject {
+	acc := widget.NewAccordion()
+	for _, rel := range releases {
+		name := rel.TagName
+		switch c := semver.Compare(rel.TagName, VERSION); {
+		case c == 0:
+			name += " (installed)"
+		case c > 0:
+			name += " (newer)"
+		}
+		if rel.Prerelease {
+			name += " - beta"
+		}
+		if !rel.PublishedAt.IsZero() {
+			name += "  " + rel.PublishedAt.Format("2006-01-02")
+		}
+		notes := widget.NewRichTextFromMarkdown(rel.Body)
+		notes.Wrapping = fyne.TextWrapWord
+		acc.Append(widget.NewAccordionItem(name, notes))
+	}
+	acc.Open(0)
+	return container.NewVScroll(acc)
 }
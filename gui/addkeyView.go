@@ -2,7 +2,9 @@ package gui
 
 import (
 	"encoding/hex"
+	"fmt"
 	"image/color"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -10,12 +12,27 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
 )
 
 func (vw *viewerWindow) addKey() {
+	slot := vw.keys.count()
+	if slot >= vw.keys.slots() {
+		dialog.ShowError(fmt.Errorf("all %d key slots are in use, delete a key first", vw.keys.slots()), vw)
+		return
+	}
+
 	ideEntry := &widget.Entry{
-		Wrapping:  fyne.TextWrapOff,
-		Validator: hexValidator(8),
+		Wrapping: fyne.TextWrapOff,
+		Validator: func(s string) error {
+			if err := hexValidator(8)(s); err != nil {
+				return err
+			}
+			if i := vw.keys.indexOf(s); i >= 0 {
+				return fmt.Errorf("IDE already used by key #%d", i)
+			}
+			return nil
+		},
 	}
 	ideEntry.OnChanged = func(s string) {
 		if len(s) > 8 {
@@ -38,7 +55,25 @@ func (vw *viewerWindow) addKey() {
 	rec := canvas.NewRectangle(color.Transparent)
 	rec.SetMinSize(fyne.NewSize(100, 10))
 
+	existing := widget.NewLabel("none")
+	if slot > 0 {
+		var keys []string
+		for i := range slot {
+			key := vw.cimBin.Keys.Data1[i]
+			keys = append(keys, fmt.Sprintf("#%d  %X  %s", i, key.Value, key.Type()))
+		}
+		existing.SetText(strings.Join(keys, "\n"))
+	}
+
 	dialog.ShowForm("Add key", "Add", "Cancel", []*widget.FormItem{
+		{
+			Text:   "Slot",
+			Widget: widget.NewLabel(fmt.Sprintf("#%d, %d free after this", slot, vw.keys.slots()-slot-1)),
+		},
+		{
+			Text:   "Keys",
+			Widget: existing,
+		},
 		{
 			Text:     "IDE",
 			HintText: "Enter IDE",
@@ -59,28 +94,25 @@ func (vw *viewerWindow) addKey() {
 				dialog.ShowError(err, vw)
 				return
 			}
-
-			if err := vw.cimBin.SetKeyID(uint8(vw.cimBin.Keys.Count1), keyID); err != nil {
-				dialog.ShowError(err, vw)
-				return
-			}
-
 			syncData, err := hex.DecodeString(syncEntry.Text)
 			if err != nil {
 				dialog.ShowError(err, vw)
 				return
 			}
 
-			if err := vw.cimBin.SetSyncData(uint8(vw.cimBin.Keys.Count1), syncData); err != nil {
-				dialog.ShowError(err, vw)
-				return
-			}
-			if err := vw.cimBin.SetKeyCount(vw.cimBin.Keys.Count1 + 1); err != nil {
-				dialog.ShowError(err, vw)
+			if i := vw.keys.indexOf(ideEntry.Text); i >= 0 {
+				dialog.ShowError(fmt.Errorf("IDE %X is already used by key #%d", keyID, i), vw)
 				return
 			}
-			//vw.tabs.Refresh()
-			vw.keyList.Refresh()
+			vw.keys.change(fmt.Sprintf("Added key #%d", slot), func(bin *cim.Bin) error {
+				if err := bin.SetKeyID(uint8(slot), keyID); err != nil {
+					return err
+				}
+				if err := bin.SetSyncData(uint8(slot), syncData); err != nil {
+					return err
+				}
+				return bin.SetKeyCount(uint8(slot + 1))
+			})
 		}
 	}, vw)
 }
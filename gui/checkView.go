@@ -0,0 +1,160 @@
+package gui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
+	"github.com/roffe/eep/eeprom"
+)
+
+// checkView lists every checksum of the region table with its stored and
+// computed value, and repairs the ones that fail.
+type checkView struct {
+	vw     *viewerWindow
+	checks []eeprom.Check
+
+	list         *widget.List
+	status       *widget.Label
+	repairButton *widget.Button
+}
+
+func newCheckView(vw *viewerWindow) *checkView {
+	cv := &checkView{
+		vw:     vw,
+		status: widget.NewLabel(""),
+	}
+	cv.repairButton = widget.NewButtonWithIcon("Repair CRCs", theme.ViewRefreshIcon(), cv.repair)
+	cv.list = &widget.List{
+		Length: func() int {
+			return len(cv.checks)
+		},
+		CreateItem: func() fyne.CanvasObject {
+			return container.NewHBox(
+				widget.NewIcon(theme.ConfirmIcon()),
+				fixedWidth(200, &widget.Label{TextStyle: fyne.TextStyle{Bold: true}, Truncation: fyne.TextTruncateEllipsis}),
+				fixedWidth(110, &widget.Label{TextStyle: fyne.TextStyle{Monospace: true}}),
+				&widget.Label{TextStyle: fyne.TextStyle{Monospace: true}},
+				layout.NewSpacer(),
+				&widget.Label{TextStyle: fyne.TextStyle{Italic: true}},
+			)
+		},
+		UpdateItem: func(item widget.ListItemID, obj fyne.CanvasObject) {
+			c := cv.checks[item]
+			row := obj.(*fyne.Container)
+			icon := row.Objects[0].(*widget.Icon)
+			result := row.Objects[5].(*widget.Label)
+			switch {
+			case c.OK():
+				icon.SetResource(theme.ConfirmIcon())
+				result.SetText("pass")
+			case c.Stored != c.Computed:
+				icon.SetResource(theme.ErrorIcon())
+				result.SetText("FAIL")
+			default:
+				icon.SetResource(theme.WarningIcon())
+				result.SetText(c.Err.Error())
+			}
+			row.Objects[1].(*fyne.Container).Objects[0].(*widget.Label).SetText(c.Name)
+			row.Objects[2].(*fyne.Container).Objects[0].(*widget.Label).SetText(fmt.Sprintf("0x%03X-0x%03X", c.Checksum.Start, c.Checksum.End))
+			row.Objects[3].(*widget.Label).SetText(fmt.Sprintf("stored %04X  computed %04X", c.Stored, c.Computed))
+		},
+	}
+	cv.list.OnSelected = func(item widget.ListItemID) {
+		cv.list.Unselect(item)
+		vw.hexEditor.selectSpan(cv.checks[item].Span)
+		vw.tabs.Select(vw.hexTab)
+	}
+	cv.reload()
+	return cv
+}
+
+func (cv *checkView) layout() fyne.CanvasObject {
+	return container.NewBorder(
+		nil,
+		container.NewBorder(nil, nil, nil, cv.repairButton, cv.status),
+		nil,
+		nil,
+		cv.list,
+	)
+}
+
+// failed returns the number of checksums that don't pass.
+func (cv *checkView) failed() int {
+	n := 0
+	for _, c := range cv.checks {
+		if !c.OK() {
+			n++
+		}
+	}
+	return n
+}
+
+// reload verifies the viewer's current image against its region table.
+func (cv *checkView) reload() {
+	cv.checks = cv.vw.regionMap.Regions.Checks(xorImage(cv.vw.data))
+	switch failed := cv.failed(); {
+	case len(cv.checks) == 0:
+		cv.status.SetText("The layout has no checksums")
+		cv.repairButton.Disable()
+	case failed == 0:
+		cv.status.SetText(fmt.Sprintf("All %d checksums pass", len(cv.checks)))
+		cv.repairButton.Disable()
+	default:
+		cv.status.SetText(fmt.Sprintf("%d of %d checksums fail", failed, len(cv.checks)))
+		cv.repairButton.Enable()
+	}
+	cv.list.Refresh()
+}
+
+// repair rewrites the failing checksums after confirmation.
+func (cv *checkView) repair() {
+	dialog.ShowConfirm("Repair CRCs", fmt.Sprintf("Rewrite the %d failing checksum(s) with the computed values?\nChecksums that pass are not touched.", cv.failed()), func(ok bool) {
+		if !ok {
+			return
+		}
+		img := xorImage(cv.vw.data)
+		fixed, err := cv.vw.regionMap.Regions.RepairChecksums(img)
+		if err != nil {
+			dialog.ShowError(err, cv.vw)
+			return
+		}
+		data := xorImage(img)
+		// Only the CIM layout is known to give a loadable CIM, other layouts
+		// are repaired regardless and hexChanged locks the CIM tabs if need be
+		if cv.vw.cimBin != nil && cv.vw.regionMap == eeprom.ForSize(eeprom.Size) {
+			if _, err := cim.MustLoadBytes("repair.bin", bytes.Clone(data)); err != nil {
+				dialog.ShowError(fmt.Errorf("repaired image is not a valid CIM, nothing was changed: %w", err), cv.vw)
+				return
+			}
+		}
+		for _, r := range fixed {
+			cv.vw.output("Repaired %s: %s", r.Name, r.Format(img[r.Start:r.End+1]))
+		}
+		cv.vw.hexEditor.Load(data)
+		cv.vw.hexChanged(data)
+	}, cv.vw)
+}
+
+// checkSummary describes the failing checksums of data, in the file form, for
+// dialogs shown before a viewer is open. It is empty when all pass.
+func checkSummary(data []byte) string {
+	checks := layoutFor(data).Regions.Checks(xorImage(data))
+	var failed []string
+	for _, c := range checks {
+		if !c.OK() {
+			failed = append(failed, c.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d of %d checksums fail: %s", len(failed), len(checks), strings.Join(failed, ", "))
+}
@@ -0,0 +1,238 @@
+package gui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/eep/eeprom"
+	sdialog "github.com/sqweek/dialog"
+)
+
+var colorDiff = rgb(160, 30, 30)
+
+// compareSource is one side of a comparison.
+type compareSource struct {
+	name    string
+	data    []byte
+	regions eeprom.Layout
+}
+
+// compareView diffs two dumps, from files or open viewer tabs, side by side.
+type compareView struct {
+	e *EEPGui
+
+	a, b *compareSource
+	diff *eeprom.Diff
+
+	labelA, labelB *widget.Label
+	gridA, gridB   *widget.TextGrid
+	status         *widget.Label
+	summary        *widget.List
+	exportText     *widget.Button
+	exportJSON     *widget.Button
+}
+
+func newCompareView(e *EEPGui) fyne.CanvasObject {
+	cv := &compareView{
+		e:       e,
+		labelA:  widget.NewLabel("not selected"),
+		labelB:  widget.NewLabel("not selected"),
+		gridA:   widget.NewTextGrid(),
+		gridB:   widget.NewTextGrid(),
+		status:  widget.NewLabel("Select two dumps to compare"),
+		summary: widget.NewList(nil, nil, nil),
+	}
+	cv.summary.Length = func() int {
+		if cv.diff == nil {
+			return 0
+		}
+		return len(cv.diff.Regions)
+	}
+	cv.summary.CreateItem = func() fyne.CanvasObject {
+		return container.NewVBox(
+			&widget.Label{TextStyle: fyne.TextStyle{Bold: true}},
+			&widget.Label{TextStyle: fyne.TextStyle{Monospace: true}, Truncation: fyne.TextTruncateEllipsis},
+		)
+	}
+	cv.summary.UpdateItem = func(item widget.ListItemID, obj fyne.CanvasObject) {
+		rc := cv.diff.Regions[item]
+		c := obj.(*fyne.Container)
+		c.Objects[0].(*widget.Label).SetText(rc.String())
+		detail := ""
+		if rc.Name != "" {
+			detail = fmt.Sprintf("0x%03X  %s -> %s", rc.Start, rc.A, rc.B)
+		}
+		c.Objects[1].(*widget.Label).SetText(detail)
+	}
+
+	cv.exportText = widget.NewButtonWithIcon("Export text", theme.DocumentSaveIcon(), func() {
+		cv.export("Text", "txt", func(d *eeprom.Diff) ([]byte, error) { return []byte(d.Text()), nil })
+	})
+	cv.exportJSON = widget.NewButtonWithIcon("Export JSON", theme.DocumentSaveIcon(), func() {
+		cv.export("JSON", "json", (*eeprom.Diff).JSON)
+	})
+	cv.exportText.Disable()
+	cv.exportJSON.Disable()
+
+	pick := func(side string, set func(*compareSource), label *widget.Label) fyne.CanvasObject {
+		return container.NewBorder(nil, nil,
+			widget.NewButtonWithIcon(side, theme.FolderOpenIcon(), func() {
+				chooseDump(cv.e, "Select dump "+side, func(src *compareSource) {
+					set(src)
+					label.SetText(src.name)
+					cv.compare()
+				})
+			}),
+			nil,
+			label,
+		)
+	}
+	swap := widget.NewButtonWithIcon("Swap", theme.ViewRefreshIcon(), func() {
+		cv.a, cv.b = cv.b, cv.a
+		cv.labelA.Text, cv.labelB.Text = cv.labelB.Text, cv.labelA.Text
+		cv.labelA.Refresh()
+		cv.labelB.Refresh()
+		cv.compare()
+	})
+
+	top := container.NewVBox(
+		container.NewGridWithColumns(2,
+			pick("A", func(s *compareSource) { cv.a = s }, cv.labelA),
+			pick("B", func(s *compareSource) { cv.b = s }, cv.labelB),
+		),
+		container.NewBorder(nil, nil, nil, container.NewHBox(swap, cv.exportText, cv.exportJSON), cv.status),
+	)
+
+	grids := container.NewScroll(container.NewHBox(cv.gridA, widget.NewSeparator(), cv.gridB))
+	split := container.NewHSplit(grids, cv.summary)
+	split.Offset = 0.8
+	return container.NewBorder(top, nil, nil, nil, split)
+}
+
+// chooseDump lets the user pick an open viewer tab or a bin file.
+func chooseDump(e *EEPGui, title string, onChosen func(*compareSource)) {
+	m := e.mw
+	var names []string
+	var sources []*compareSource
+	for _, item := range m.docTab.Items {
+		vw, ok := m.viewers[item.Content]
+		if !ok {
+			continue
+		}
+		names = append(names, item.Text)
+		sources = append(sources, &compareSource{name: item.Text, data: vw.data, regions: vw.regionMap.Regions})
+	}
+
+	var d *dialog.CustomDialog
+	tabs := widget.NewList(
+		func() int { return len(names) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(item widget.ListItemID, obj fyne.CanvasObject) { obj.(*widget.Label).SetText(names[item]) },
+	)
+	tabs.OnSelected = func(item widget.ListItemID) {
+		d.Hide()
+		// Snapshot the data so later edits in the viewer don't change the diff
+		src := *sources[item]
+		src.data = append([]byte(nil), src.data...)
+		onChosen(&src)
+	}
+	open := widget.NewButtonWithIcon("Open file...", theme.FolderOpenIcon(), func() {
+		d.Hide()
+		go func() {
+			filename, err := sdialog.File().Filter("Bin file", "bin").Title(title).Load()
+			if err != nil {
+				if err.Error() != "Cancelled" {
+					m.output("%s", err)
+				}
+				return
+			}
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				fyne.Do(func() { dialog.ShowError(err, m) })
+				return
+			}
+			fyne.Do(func() {
+				onChosen(&compareSource{name: filepath.Base(filename), data: data, regions: layoutFor(data).Regions})
+			})
+		}()
+	})
+
+	var content fyne.CanvasObject = widget.NewLabel("No dumps are open")
+	if len(names) > 0 {
+		content = tabs
+	}
+	d = dialog.NewCustom(title, "Cancel", container.NewBorder(widget.NewLabel("Open tabs"), open, nil, nil, content), m)
+	d.Resize(fyne.NewSize(400, 320))
+	d.Show()
+}
+
+func (cv *compareView) compare() {
+	if cv.a == nil || cv.b == nil {
+		return
+	}
+	cv.diff = eeprom.Compare(cv.a.name, xorImage(cv.a.data), cv.b.name, xorImage(cv.b.data), cv.a.regions)
+
+	rowsA := generateGrid(cv.a.data, cv.a.regions)
+	rowsB := generateGrid(cv.b.data, cv.b.regions)
+	for _, bc := range cv.diff.Bytes {
+		highlightByte(rowsA, bc.Offset, colorDiff)
+		highlightByte(rowsB, bc.Offset, colorDiff)
+	}
+	cv.gridA.Rows = rowsA
+	cv.gridB.Rows = rowsB
+	cv.gridA.Refresh()
+	cv.gridB.Refresh()
+
+	switch {
+	case cv.diff.Equal():
+		cv.status.SetText("Dumps are identical")
+	default:
+		var changed []string
+		for _, rc := range cv.diff.Regions {
+			if rc.Name != "" {
+				changed = append(changed, rc.Name)
+			}
+		}
+		cv.status.SetText(fmt.Sprintf("%d byte(s) differ in %d region(s)", len(cv.diff.Bytes), len(changed)))
+		cv.e.mw.output("Compare %s with %s: %s", cv.a.name, cv.b.name, strings.Join(changed, ", "))
+	}
+	if len(cv.a.data) != len(cv.b.data) {
+		cv.status.SetText(cv.status.Text + fmt.Sprintf(", sizes differ (%d and %d bytes)", len(cv.a.data), len(cv.b.data)))
+	}
+	cv.summary.Refresh()
+	cv.exportText.Enable()
+	cv.exportJSON.Enable()
+}
+
+func (cv *compareView) export(kind, ext string, render func(*eeprom.Diff) ([]byte, error)) {
+	if cv.diff == nil {
+		return
+	}
+	out, err := render(cv.diff)
+	if err != nil {
+		dialog.ShowError(err, cv.e.mw)
+		return
+	}
+	go func() {
+		filename, err := sdialog.File().Filter(kind, ext).SetStartFile("diff." + ext).Title("Export diff").Save()
+		if err != nil {
+			if err.Error() != "Cancelled" {
+				cv.e.mw.output("%s", err)
+			}
+			return
+		}
+		filename = addSuffix(filename, "."+ext)
+		if err := os.WriteFile(filename, out, 0644); err != nil {
+			fyne.Do(func() { dialog.ShowError(err, cv.e.mw) })
+			return
+		}
+		cv.e.mw.output("Exported diff to %s", filename)
+	}()
+}
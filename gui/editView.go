@@ -1,32 +1,250 @@
 package gui
 
 import (
+	"fmt"
+	"strings"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
+	"github.com/roffe/eep/eeprom"
 )
 
+// editView edits the CIM image region by region. Edits go to a working copy
+// and only reach the viewer when saved, after checksums are recomputed and
+// the result loads as a valid CIM.
 type editView struct {
-	vw *viewerWindow
+	vw      *viewerWindow
+	regions eeprom.Layout
+
+	img     []byte
+	changed []eeprom.Span
+
+	list         *widget.List
+	status       *widget.Label
+	saveButton   *widget.Button
+	revertButton *widget.Button
 }
 
-func newEditView(vw *viewerWindow) fyne.CanvasObject {
+func newEditView(vw *viewerWindow) *editView {
 	ev := &editView{
-		vw: vw,
+		vw:      vw,
+		regions: vw.regionMap.Regions,
+		status:  widget.NewLabel(""),
 	}
-	return ev.layout()
+	ev.saveButton = widget.NewButtonWithIcon("Save changes", theme.DocumentSaveIcon(), ev.save)
+	ev.revertButton = widget.NewButtonWithIcon("Revert", theme.ContentUndoIcon(), ev.reload)
+	ev.list = &widget.List{
+		Length: func() int {
+			return len(ev.regions)
+		},
+		CreateItem: func() fyne.CanvasObject {
+			return container.NewHBox(
+				fixedWidth(200, &widget.Label{TextStyle: fyne.TextStyle{Bold: true}, Truncation: fyne.TextTruncateEllipsis}),
+				fixedWidth(70, &widget.Label{TextStyle: fyne.TextStyle{Monospace: true}}),
+				fixedWidth(50, &widget.Label{}),
+				&widget.Label{TextStyle: fyne.TextStyle{Monospace: true}},
+				layout.NewSpacer(),
+				&widget.Label{TextStyle: fyne.TextStyle{Italic: true}},
+				widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {}),
+			)
+		},
+		UpdateItem: func(item widget.ListItemID, obj fyne.CanvasObject) {
+			r := ev.regions[item]
+			c := obj.(*fyne.Container)
+			c.Objects[0].(*fyne.Container).Objects[0].(*widget.Label).SetText(r.Name)
+			c.Objects[1].(*fyne.Container).Objects[0].(*widget.Label).SetText(fmt.Sprintf("0x%03X", r.Start))
+			c.Objects[2].(*fyne.Container).Objects[0].(*widget.Label).SetText(fmt.Sprintf("%d B", r.Len()))
+			c.Objects[3].(*widget.Label).SetText(ev.value(r))
+			c.Objects[5].(*widget.Label).SetText(ev.note(r))
+			c.Objects[6].(*widget.Button).OnTapped = func() {
+				ev.edit(r)
+			}
+		},
+	}
+	ev.reload()
+	return ev
 }
 
 func (ev *editView) layout() fyne.CanvasObject {
-	return container.NewBorder(ev.vw.toolbar, nil, nil, nil,
-		container.NewVBox(
-			widget.NewLabel("Here the full eeprom editor will soon come"),
-			layout.NewSpacer(),
-			widget.NewButtonWithIcon("Close", theme.ContentClearIcon(), func() {
-				ev.vw.SetContent(ev.vw.layout())
-			}),
-		),
+	return container.NewBorder(
+		nil,
+		container.NewBorder(nil, nil, nil, container.NewHBox(ev.revertButton, ev.saveButton), ev.status),
+		nil,
+		nil,
+		ev.list,
 	)
 }
+
+// setLayout switches to another region map, dropping pending edits.
+func (ev *editView) setLayout(regions eeprom.Layout) {
+	ev.regions = regions
+	ev.reload()
+}
+
+// reload discards pending edits and starts over from the viewer's CIM.
+func (ev *editView) reload() {
+	// vw.data rather than vw.cimBin, so hex edits that don't load as a CIM
+	// yet can be fixed here
+	ev.img = xorImage(ev.vw.data)
+	ev.changed = nil
+	ev.update()
+}
+
+// dirty reports whether there are unsaved edits.
+func (ev *editView) dirty() bool {
+	return len(ev.changed) > 0
+}
+
+func (ev *editView) update() {
+	if ev.dirty() {
+		ev.status.SetText(fmt.Sprintf("%d unsaved change(s)", len(ev.changed)))
+		ev.saveButton.Enable()
+		ev.revertButton.Enable()
+	} else {
+		ev.status.SetText("No changes")
+		ev.saveButton.Disable()
+		ev.revertButton.Disable()
+	}
+	ev.list.Refresh()
+}
+
+func (ev *editView) bytes(r eeprom.Region) []byte {
+	if r.End >= len(ev.img) {
+		return nil
+	}
+	return ev.img[r.Start : r.End+1]
+}
+
+func (ev *editView) value(r eeprom.Region) string {
+	v := r.Format(ev.bytes(r))
+	if len(v) > 48 {
+		v = v[:45] + "..."
+	}
+	return v
+}
+
+func (ev *editView) note(r eeprom.Region) string {
+	var notes []string
+	for _, c := range ev.changed {
+		if c.Overlaps(r.Span) {
+			notes = append(notes, "modified")
+			break
+		}
+	}
+	if m, ok := ev.regions.Mirror(r); ok {
+		notes = append(notes, "mirrors "+m.Name)
+	}
+	if r.Checksum != nil {
+		notes = append(notes, fmt.Sprintf("CRC of 0x%03X-0x%03X", r.Checksum.Start, r.Checksum.End))
+	}
+	return strings.Join(notes, ", ")
+}
+
+// edit asks for a new value of r, as hex or in the region's own form.
+func (ev *editView) edit(r eeprom.Region) {
+	current := ev.bytes(r)
+	hexEntry := &widget.Entry{
+		Text:      fmt.Sprintf("%X", current),
+		Validator: func(s string) error { _, err := r.ParseHex(s); return err },
+	}
+	items := []*widget.FormItem{
+		widget.NewFormItem("Offset", widget.NewLabel(fmt.Sprintf("0x%03X-0x%03X (%d bytes)", r.Start, r.End, r.Len()))),
+		widget.NewFormItem("Hex", hexEntry),
+	}
+
+	if r.Kind != eeprom.Hex {
+		typedEntry := &widget.Entry{
+			Text:      r.Format(current),
+			Validator: func(s string) error { _, err := r.Parse(s); return err },
+		}
+		// Keep both entries showing the same value
+		var syncing bool
+		typedEntry.OnChanged = func(s string) {
+			if b, err := r.Parse(s); err == nil && !syncing {
+				syncing = true
+				hexEntry.SetText(fmt.Sprintf("%X", b))
+				syncing = false
+			}
+		}
+		hexEntry.OnChanged = func(s string) {
+			if b, err := r.ParseHex(s); err == nil && !syncing {
+				syncing = true
+				typedEntry.SetText(r.Format(b))
+				syncing = false
+			}
+		}
+		items = append(items, widget.NewFormItem(strings.ToUpper(r.Kind.String()[:1])+r.Kind.String()[1:], typedEntry))
+	}
+	if m, ok := ev.regions.Mirror(r); ok {
+		items = append(items, widget.NewFormItem("Mirror", widget.NewLabel("Also written to "+m.Name)))
+	}
+	if r.Checksum != nil {
+		items = append(items, widget.NewFormItem("Checksum", widget.NewLabel("Recomputed on save when the data it covers changes")))
+	}
+
+	d := dialog.NewForm("Edit "+r.Name, "Apply", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		value, err := r.ParseHex(hexEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, ev.vw)
+			return
+		}
+		changed, err := ev.regions.Set(ev.img, r, value)
+		if err != nil {
+			dialog.ShowError(err, ev.vw)
+			return
+		}
+		ev.changed = append(ev.changed, changed...)
+		ev.update()
+	}, ev.vw)
+	d.Resize(fyne.NewSize(480, 0))
+	d.Show()
+}
+
+// save recomputes the checksums over the edited regions and hands the image
+// to the viewer if it loads as a valid CIM.
+func (ev *editView) save() {
+	img := append([]byte(nil), ev.img...)
+	fixed, err := ev.regions.FixChecksums(img, ev.changed)
+	if err != nil {
+		dialog.ShowError(err, ev.vw)
+		return
+	}
+	data := xorImage(img)
+	bin, err := cim.MustLoadBytes("edit.bin", append([]byte(nil), data...))
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("edited image is not a valid CIM: %w", err), ev.vw)
+		return
+	}
+
+	for _, r := range fixed {
+		ev.vw.output("Recomputed %s: %s", r.Name, r.Format(img[r.Start:r.End+1]))
+	}
+	ev.vw.output("Applied %d EEPROM edit(s)", len(ev.changed))
+	// Edits aren't on the viewer's undo stack, the hex view's keeps them
+	ev.vw.hexEditor.Load(data)
+	ev.vw.data = data
+	ev.vw.cimBin = bin
+	ev.vw.unparsed = false
+	ev.vw.askSaveOnClose = true
+	ev.vw.saved = false
+	ev.vw.refreshTabs()
+	ev.reload()
+}
+
+// xorImage converts between the plain image and the inverted form CIM dumps
+// are stored and written in.
+func xorImage(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[i] = c ^ 0xFF
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+package gui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/eep/adapter"
+	"github.com/roffe/eep/avr"
+)
+
+// prefFirmwareIgnore lists the USB serial numbers of adapters the user asked
+// not to be prompted about again.
+const prefFirmwareIgnore = "firmware_notice_ignore"
+
+// adapterOutdated is the adapter client's OnOutdated callback. The notice is
+// held back until the running job has released the port.
+func (e *EEPGui) adapterOutdated(version string) {
+	port := e.port
+	serial, err := adapter.SerialNumber(port)
+	if err != nil {
+		serial = ""
+	}
+	fyne.Do(func() {
+		if serial != "" && slices.Contains(e.Preferences().StringList(prefFirmwareIgnore), serial) {
+			return
+		}
+		key := serial
+		if key == "" {
+			key = port
+		}
+		if e.firmwarePrompted[key] {
+			return
+		}
+		e.firmwarePrompted[key] = true
+		e.pendingFirmware = &firmwareNotice{port: port, serial: serial, version: version}
+	})
+}
+
+type firmwareNotice struct {
+	port    string
+	serial  string
+	version string
+}
+
+// showFirmwareNotice shows a pending outdated firmware notice, if any.
+// It must be called on the fyne goroutine.
+func (e *EEPGui) showFirmwareNotice() {
+	n := e.pendingFirmware
+	if n == nil {
+		return
+	}
+	e.pendingFirmware = nil
+
+	hwVer, err := e.hwVersion.Get()
+	if err != nil {
+		hwVer = "Uno"
+	}
+
+	dontAsk := widget.NewCheck("Don't ask again for this adapter", nil)
+	if n.serial == "" {
+		dontAsk.Disable()
+	}
+	content := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("The adapter on %s is running firmware %s, CIM Tool ships %s.", n.port, n.version, VERSION)),
+		widget.NewLabel(fmt.Sprintf("Update the Arduino %s now? Keep the adapter connected until the update is done.", hwVer)),
+		dontAsk,
+	)
+
+	d := dialog.NewCustomWithoutButtons("Adapter firmware", content, e.mw)
+	d.SetOnClosed(func() {
+		if dontAsk.Checked {
+			ignored := e.Preferences().StringList(prefFirmwareIgnore)
+			if !slices.Contains(ignored, n.serial) {
+				e.Preferences().SetStringList(prefFirmwareIgnore, append(ignored, n.serial))
+			}
+		}
+	})
+	d.SetButtons([]fyne.CanvasObject{
+		widget.NewButton("Later", d.Hide),
+		&widget.Button{Text: "Update adapter firmware now", Icon: theme.UploadIcon(), Importance: widget.HighImportance, OnTapped: func() {
+			d.Hide()
+			e.updateAdapterFirmware(n.port, hwVer)
+		}},
+	})
+	d.Show()
+}
+
+// updateAdapterFirmware flashes the bundled firmware to the adapter on port
+// and checks it answers with the expected version afterwards.
+func (e *EEPGui) updateAdapterFirmware(port, hwVer string) {
+	if e.firmwareBusy {
+		dialog.ShowError(errors.New("a firmware job is already running"), e.mw)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stage := widget.NewLabel("")
+	progress := widget.NewProgressBar()
+	d := dialog.NewCustom("Updating adapter firmware", "Cancel", container.NewVBox(stage, progress), e.mw)
+	d.SetOnClosed(cancel)
+	d.Resize(fyne.NewSize(400, 120))
+	d.Show()
+
+	onEvent := func(ev avr.Event) {
+		if ev.Message != "" {
+			e.mw.output("%s", ev.Message)
+		}
+		fyne.Do(func() {
+			if ev.Pages > 0 {
+				stage.SetText(fmt.Sprintf("%s page %d/%d", ev.Stage, ev.Page, ev.Pages))
+				progress.Max = float64(ev.Pages)
+				progress.SetValue(float64(ev.Page))
+				return
+			}
+			stage.SetText(ev.Stage.String())
+		})
+	}
+
+	e.setFirmwareBusy(nil, true)
+	e.mw.disableButtons()
+	go func() {
+		defer fyne.Do(func() { e.setFirmwareBusy(nil, false) })
+		defer e.mw.enableButtons()
+		defer cancel()
+
+		report, err := avr.Update(ctx, port, hwVer, onEvent)
+		if report != nil {
+			e.mw.output("%s", report.String())
+		}
+		if err == nil && report.NewVersion != VERSION {
+			err = fmt.Errorf("adapter answers %s after update, expected %s", report.NewVersion, VERSION)
+		}
+		fyne.Do(func() {
+			d.Hide()
+			switch {
+			case errors.Is(err, context.Canceled):
+				e.mw.output("Adapter firmware update cancelled")
+			case err != nil:
+				e.mw.output("Adapter firmware update failed: %v", err)
+				dialog.ShowError(err, e.mw)
+			default:
+				e.mw.output("Adapter firmware updated to %s", VERSION)
+				dialog.ShowInformation("Adapter firmware", fmt.Sprintf("Adapter is now running %s\n\n%s", VERSION, report.String()), e.mw)
+			}
+		})
+	}()
+}
@@ -1,11 +1,12 @@
 package gui
 
 import (
+	"context"
 	"net/url"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/data/binding"
-	"fyne.io/fyne/v2/dialog"
+	"github.com/roffe/eep/history"
 	"github.com/roffe/eep/update"
 	"golang.org/x/mod/semver"
 )
@@ -19,6 +20,18 @@ type EEPGui struct {
 	writeDelayValue binding.Float
 	ignoreError     binding.Bool
 	verifyWrite     binding.Bool
+	updateEndpoint  binding.String
+	updateChannel   binding.String
+
+	firmwarePrompted map[string]bool
+	pendingFirmware  *firmwareNotice
+	// firmwareBusy is set while a firmware job runs, firmwareViews are the
+	// settings views whose firmware buttons it disables
+	firmwareBusy  bool
+	firmwareViews []*settingsWindow
+
+	// history is nil when the job history could not be opened
+	history *history.Store
 
 	mw *mainWindow
 	sw *settingsWindow
@@ -33,12 +46,21 @@ func New(app fyne.App) (*EEPGui, error) {
 		writeDelayValue: binding.NewFloat(),
 		ignoreError:     binding.NewBool(),
 		verifyWrite:     binding.NewBool(),
+		updateEndpoint:  binding.NewString(),
+		updateChannel:   binding.NewString(),
+
+		firmwarePrompted: make(map[string]bool),
 	}
 
 	if err := loadPrefs(eep); err != nil {
 		return nil, err
 	}
+	store, historyErr := eep.openHistory()
+	eep.history = store
 	eep.mw = newMainWindow(eep)
+	if historyErr != nil {
+		eep.mw.output("Job history disabled: %v", historyErr)
+	}
 
 	return eep, nil
 }
@@ -74,15 +96,34 @@ func loadPrefs(e *EEPGui) error {
 	if err := e.verifyWrite.Set(verifyWrite); err != nil {
 		return err
 	}
+
+	updateEndpoint := prefs.StringWithFallback("update_endpoint", update.DefaultEndpoint)
+	if err := e.updateEndpoint.Set(updateEndpoint); err != nil {
+		return err
+	}
+
+	updateChannel := prefs.StringWithFallback("update_channel", string(update.Stable))
+	if err := e.updateChannel.Set(updateChannel); err != nil {
+		return err
+	}
 	return nil
 }
 
+// CheckUpdate looks for a newer release on the selected channel in the
+// background and offers to download it.
 func (e *EEPGui) CheckUpdate() {
-	if latest, err := update.GetLatest(); err == nil {
-		if semver.Compare(latest.TagName, VERSION) > 0 {
-			dialog.ShowConfirm("Software update", "There is a new version available, would you like to visit the download page?", e.openWebpage, e.mw)
+	go func() {
+		latest, err := e.updateClient().LatestIn(context.Background(), e.channel())
+		if err != nil {
+			e.mw.output("Update check failed: %v", err)
+			return
 		}
-	}
+		if semver.Compare(latest.TagName, VERSION) <= 0 {
+			e.mw.output("CIM Tool is up to date, latest release is %s", latest.TagName)
+			return
+		}
+		fyne.Do(func() { e.showUpdate(latest) })
+	}()
 }
 
 var releasepageURL = &url.URL{Scheme: "https", Host: "github.com", Path: "/roffe/eep/releases/latest"}
@@ -1,20 +1,12 @@
 package gui
 
 import (
-	"sync"
-
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/roffe/eep/assets"
-	"github.com/roffe/eep/update"
-)
-
-var (
-	getReleasesOnce sync.Once
-	changesContent  []fyne.CanvasObject
 )
 
 func newHelpWindow(e *EEPGui) fyne.Window {
@@ -84,19 +76,7 @@ func newHelpWindow(e *EEPGui) fyne.Window {
 		),
 	)
 
-	getReleasesOnce.Do(func() {
-		for _, rel := range update.GetReleases() {
-			changesContent = append(changesContent, widget.NewRichTextFromMarkdown("# "+rel.TagName+"  \n\n"+rel.Body))
-		}
-	})
-
-	/*
-		changesTab := container.NewTabItemWithIcon("Changelog", theme.InfoIcon(),
-			container.NewVScroll(
-				container.NewVBox(changesContent...),
-			),
-		)
-	*/
+	changesTab := container.NewTabItemWithIcon("Changelog", theme.InfoIcon(), newChangelogView(e))
 
 	w := e.NewWindow("Help")
 	w.SetOnClosed(func() {
@@ -107,7 +87,7 @@ func newHelpWindow(e *EEPGui) fyne.Window {
 		introTab,
 		failedTab,
 		settingsTab,
-		//changesTab,
+		changesTab,
 	))
 	w.Resize(fyne.NewSize(920, 800))
 	w.Show()
@@ -2,50 +2,598 @@ package gui
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"image/color"
 	"io"
+	"strconv"
+	"strings"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
+	"github.com/roffe/eep/eeprom"
+)
+
+// Grid geometry as laid out by generateGrid.
+const (
+	hexRowWidth  = 32
+	hexFirstCol  = 4                                   // after the offset column
+	hexAsciiCol  = hexFirstCol + hexRowWidth*3 - 1 + 3 // after the ║ separator
+	hexHeaderRow = 1
+)
+
+var (
+	colorCursor    = rgb(255, 200, 0)
+	colorSelection = color.RGBA{R: 80, G: 120, B: 200, A: 160}
 )
 
 func newHexView(vw *viewerWindow) fyne.CanvasObject {
-	grid := widget.NewTextGrid()
-	grid.Rows = generateGrid(vw.data)
-	/*
-			home := func() {
-				vw.w.SetContent(vw.layout())
-				vw.w.Resize(viewWindowSize)
+	vw.hexEditor = newHexEditor(vw.data, vw.regionMap.Regions, vw.hexChanged)
+
+	status := widget.NewLabel("")
+	vw.hexEditor.onCursor = func() {
+		status.SetText(vw.hexEditor.describeCursor())
+	}
+	vw.hexEditor.onCursor()
+
+	goTo := func() {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder("0x1A0")
+		dialog.ShowForm("Go to offset", "Go", "Cancel", []*widget.FormItem{
+			widget.NewFormItem("Offset", entry),
+		}, func(ok bool) {
+			if !ok {
+				return
 			}
-			xorHex := func() {
-				for i := range vw.data {
-				vw.data[i] ^= 0xff
+			off, err := strconv.ParseInt(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(entry.Text)), "0x"), 16, 32)
+			if err != nil || int(off) >= vw.hexEditor.buf.Len() {
+				dialog.ShowError(fmt.Errorf("invalid offset %q", entry.Text), vw)
+				return
 			}
-			grid.Rows = generateGrid(vw.data)
-			grid.Refresh()
+			vw.hexEditor.moveTo(int(off), false)
+			vw.hexEditor.focus()
+		}, vw)
+	}
+	vw.hexEditor.onGoTo = goTo
+
+	toolbar := widget.NewToolbar(
+		widget.NewToolbarAction(theme.ContentUndoIcon(), vw.hexEditor.undo),
+		widget.NewToolbarAction(theme.ContentRedoIcon(), vw.hexEditor.redo),
+		widget.NewToolbarSeparator(),
+		widget.NewToolbarAction(theme.ContentCopyIcon(), vw.hexEditor.copy),
+		widget.NewToolbarAction(theme.ContentPasteIcon(), vw.hexEditor.paste),
+		widget.NewToolbarSeparator(),
+		widget.NewToolbarAction(theme.SearchIcon(), goTo),
+	)
+	return container.NewBorder(
+		container.NewBorder(nil, nil, toolbar, nil, status),
+		nil,
+		nil,
+		newHexLegend(vw.hexEditor),
+		vw.hexEditor,
+	)
+}
+
+// newHexLegend lists the regions in their hex view colours. Selecting one
+// selects its bytes in the editor.
+func newHexLegend(h *hexEditor) fyne.CanvasObject {
+	list := &widget.List{
+		Length: func() int {
+			return len(h.regions)
+		},
+		CreateItem: func() fyne.CanvasObject {
+			swatch := canvas.NewRectangle(rgb(255, 255, 255))
+			swatch.SetMinSize(fyne.NewSize(12, 12))
+			return container.NewHBox(
+				container.NewCenter(swatch),
+				&widget.Label{},
+				&widget.Label{TextStyle: fyne.TextStyle{Monospace: true}},
+			)
+		},
+		UpdateItem: func(item widget.ListItemID, obj fyne.CanvasObject) {
+			r := h.regions[item]
+			row := obj.(*fyne.Container)
+			swatch := row.Objects[0].(*fyne.Container).Objects[0].(*canvas.Rectangle)
+			swatch.FillColor = r.Color
+			swatch.Refresh()
+			row.Objects[1].(*widget.Label).SetText(r.Name)
+			row.Objects[2].(*widget.Label).SetText(fmt.Sprintf("%03X", r.Start))
+		},
+	}
+	list.OnSelected = func(item widget.ListItemID) {
+		h.selectSpan(h.regions[item].Span)
+	}
+	h.onLayout = list.Refresh
+	return container.NewBorder(
+		widget.NewLabelWithStyle("Regions", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		nil, nil, nil,
+		fixedWidth(260, list),
+	)
+}
+
+var errUnparsed = errors.New("the hex edits don't load as a CIM, fix them on the Hex, Edit, Checks or Mirrors tab first")
+
+// editable returns errUnparsed while the changes made to vw.cimBin would be
+// lost on the hex edits held in vw.data.
+func (vw *viewerWindow) editable() error {
+	if vw.unparsed {
+		return errUnparsed
+	}
+	return nil
+}
+
+// hexChanged takes an edit from the hex editor into the viewer, re-parsing
+// the CIM when the new data loads. Until it does, vw.data holds the edit and
+// the tabs built from vw.cimBin are locked, see editable.
+func (vw *viewerWindow) hexChanged(data []byte) {
+	vw.data = data
+	vw.saved = false
+	vw.askSaveOnClose = true
+	vw.checks.reload()
+	vw.mirrors.reload()
+	bin, err := cim.MustLoadBytes("edit.bin", bytes.Clone(data))
+	if vw.cimBin == nil {
+		if err == nil {
+			vw.output("Hex edit made the image load as a CIM, reopening it")
+			vw.promote()
 		}
-	*/
-
-	//vw.toolbar.Append(widget.NewToolbarAction(theme.ContentClearIcon(), xorHex))
-	/*
-		return container.NewBorder(
-			//widget.NewToolbar(
-			//	widget.NewToolbarAction(theme.HomeIcon(), home),
-			//	widget.NewToolbarAction(theme.DocumentSaveIcon(), vw.save),
-			//	widget.NewToolbarAction(theme.ContentClearIcon(), xorHex),
-			//),
-			vw.toolbar,
-			nil,
-			nil,
-			nil,
-			grid,
-		)
-	*/
-	return grid
+		return
+	}
+	if err != nil {
+		if !vw.unparsed {
+			vw.output("Hex edit does not load as a CIM, saving keeps the raw image and the other tabs are locked until it does: %v", err)
+		}
+		vw.unparsed = true
+		return
+	}
+	vw.unparsed = false
+	vw.cimBin = bin
+	vw.info.reload()
+	vw.versionTab.Content = vw.renderVersionTab()
+	vw.vinTab.Content = vw.renderVinTab()
+	vw.keys.refresh()
+	vw.tabs.Refresh()
+}
+
+// hexEditor is an overwrite mode hex editor over a fixed size buffer, with
+// a hex and an ASCII pane.
+type hexEditor struct {
+	widget.BaseWidget
+
+	grid *widget.TextGrid
+	buf  *eeprom.Buffer
+
+	cursor     int
+	lowNibble  bool // next hex digit goes into the low nibble
+	nibbleStep bool // the high nibble was written as an undo step of its own
+	ascii      bool // typing goes to the ASCII pane
+	anchor     int  // selection start, -1 without a selection
+	shift      bool
+	dragging   bool
+
+	regions eeprom.Layout
+	tip     *fyne.Container
+	tipText *widget.Label
+
+	onChanged func(data []byte)
+	onCursor  func()
+	onGoTo    func()
+	onLayout  func()
+}
+
+func newHexEditor(data []byte, regions eeprom.Layout, onChanged func(data []byte)) *hexEditor {
+	h := &hexEditor{
+		grid:      widget.NewTextGrid(),
+		buf:       eeprom.NewBuffer(data),
+		anchor:    -1,
+		regions:   regions,
+		tipText:   &widget.Label{TextStyle: fyne.TextStyle{Monospace: true}},
+		onChanged: onChanged,
+		onCursor:  func() {},
+		onLayout:  func() {},
+	}
+	h.tip = container.NewStack(canvas.NewRectangle(theme.Color(theme.ColorNameOverlayBackground)), h.tipText)
+	h.tip.Hide()
+	h.ExtendBaseWidget(h)
+	h.render()
+	return h
+}
+
+func (h *hexEditor) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewStack(h.grid, container.NewWithoutLayout(h.tip)))
+}
+
+// Load replaces the contents as one undoable edit.
+func (h *hexEditor) Load(data []byte) {
+	if err := h.buf.Replace(data); err != nil {
+		h.buf = eeprom.NewBuffer(data)
+		h.cursor, h.anchor = 0, -1
+	}
+	h.render()
+}
+
+// Reload shows data without an undo step, for changes the viewer undoes on
+// its own stack.
+func (h *hexEditor) Reload(data []byte) {
+	if err := h.buf.Set(data); err != nil {
+		h.buf = eeprom.NewBuffer(data)
+		h.cursor, h.anchor = 0, -1
+	}
+	h.render()
+}
+
+// SetLayout recolours the view for another region map.
+func (h *hexEditor) SetLayout(regions eeprom.Layout) {
+	h.regions = regions
+	h.render()
+	h.onLayout()
+}
+
+func (h *hexEditor) render() {
+	h.grid.Rows = generateGrid(h.buf.Bytes(), h.regions)
+	if sel, ok := h.selection(); ok {
+		for pos := sel.Start; pos <= sel.End; pos++ {
+			h.highlight(pos, colorSelection)
+		}
+	}
+	if h.buf.Len() > 0 {
+		h.highlight(h.cursor, colorCursor)
+	}
+	h.grid.Refresh()
+	h.onCursor()
+}
+
+func (h *hexEditor) highlight(pos int, bg color.Color) {
+	highlightByte(h.grid.Rows, pos, bg)
+}
+
+// highlightByte sets the background of the hex and ASCII cells of the byte at
+// pos in rows made by generateGrid.
+func highlightByte(rows []widget.TextGridRow, pos int, bg color.Color) {
+	row := hexHeaderRow + pos/hexRowWidth
+	col := pos % hexRowWidth
+	for _, c := range []int{hexFirstCol + col*3, hexFirstCol + col*3 + 1, hexAsciiCol + col} {
+		if row >= len(rows) || c >= len(rows[row].Cells) {
+			continue
+		}
+		cell := &rows[row].Cells[c]
+		style := &widget.CustomTextGridStyle{BGColor: bg}
+		if cs, ok := cell.Style.(*widget.CustomTextGridStyle); ok {
+			style.FGColor = cs.FGColor
+			style.TextStyle = cs.TextStyle
+		}
+		cell.Style = style
+	}
+}
+
+func (h *hexEditor) selection() (eeprom.Span, bool) {
+	if h.anchor < 0 || h.anchor == h.cursor {
+		return eeprom.Span{}, false
+	}
+	return eeprom.Span{Start: min(h.anchor, h.cursor), End: max(h.anchor, h.cursor)}, true
+}
+
+func (h *hexEditor) describeCursor() string {
+	pane := "hex"
+	if h.ascii {
+		pane = "ASCII"
+	}
+	s := fmt.Sprintf("Offset 0x%03X (%s)", h.cursor, pane)
+	if sel, ok := h.selection(); ok {
+		s += fmt.Sprintf("  selected 0x%03X-0x%03X (%d bytes)", sel.Start, sel.End, sel.Len())
+	}
+	if r, ok := h.regions.At(h.cursor); ok {
+		s += fmt.Sprintf("  %s 0x%03X-0x%03X (%d bytes) = %s", r.Name, r.Start, r.End, r.Len(), h.decode(r))
+	}
+	return s
+}
+
+// describe names the region at pos with its offset, length and value.
+func (h *hexEditor) describe(pos int) string {
+	s := fmt.Sprintf("0x%03X = %02X", pos, h.buf.At(pos))
+	r, ok := h.regions.At(pos)
+	if !ok {
+		return s + "\nno region"
+	}
+	return fmt.Sprintf("%s\n%s\n0x%03X-0x%03X, %d bytes\n%s", r.Name, s, r.Start, r.End, r.Len(), h.decode(r))
+}
+
+// decode formats the region's value. CIM dumps are stored inverted, so the
+// bytes are flipped back before decoding.
+func (h *hexEditor) decode(r eeprom.Region) string {
+	if r.End >= h.buf.Len() {
+		return ""
+	}
+	v := r.Format(xorImage(h.buf.Slice(r.Span)))
+	if len(v) > 40 {
+		v = v[:37] + "..."
+	}
+	return v
+}
+
+// selectSpan selects s and puts the cursor at its end.
+func (h *hexEditor) selectSpan(s eeprom.Span) {
+	if s.End >= h.buf.Len() {
+		return
+	}
+	h.anchor = s.Start
+	h.cursor = s.End
+	h.lowNibble = false
+	if s.Start == s.End {
+		h.anchor = -1
+	}
+	h.render()
+}
+
+func (h *hexEditor) MouseIn(ev *desktop.MouseEvent) {
+	h.MouseMoved(ev)
+}
+
+// MouseMoved shows the tooltip for the byte under the pointer.
+func (h *hexEditor) MouseMoved(ev *desktop.MouseEvent) {
+	pos, _, ok := h.posAt(ev.Position)
+	if !ok {
+		h.tip.Hide()
+		return
+	}
+	h.tipText.SetText(h.describe(pos))
+	size := h.tip.MinSize()
+	at := ev.Position.Add(fyne.NewPos(16, 16))
+	if at.X+size.Width > h.Size().Width {
+		at.X = max(0, ev.Position.X-size.Width-8)
+	}
+	if at.Y+size.Height > h.Size().Height {
+		at.Y = max(0, ev.Position.Y-size.Height-8)
+	}
+	h.tip.Resize(size)
+	h.tip.Move(at)
+	h.tip.Show()
+}
+
+func (h *hexEditor) MouseOut() {
+	h.tip.Hide()
+}
+
+// moveTo puts the cursor at pos, extending the selection if extend is set.
+func (h *hexEditor) moveTo(pos int, extend bool) {
+	pos = max(0, min(pos, h.buf.Len()-1))
+	switch {
+	case extend && h.anchor < 0:
+		h.anchor = h.cursor
+	case !extend:
+		h.anchor = -1
+	}
+	h.cursor = pos
+	h.lowNibble = false
+	h.render()
+}
+
+func (h *hexEditor) focus() {
+	if c := fyne.CurrentApp().Driver().CanvasForObject(h); c != nil {
+		c.Focus(h)
+	}
+}
+
+// posAt maps a point on the grid to a byte offset and pane.
+func (h *hexEditor) posAt(p fyne.Position) (pos int, ascii bool, ok bool) {
+	row, col := h.grid.CursorLocationForPosition(p)
+	row -= hexHeaderRow
+	if row < 0 {
+		return 0, false, false
+	}
+	switch {
+	case col >= hexAsciiCol && col < hexAsciiCol+hexRowWidth:
+		pos, ascii = row*hexRowWidth+col-hexAsciiCol, true
+	case col >= hexFirstCol && col < hexAsciiCol-3:
+		pos = row*hexRowWidth + (col-hexFirstCol)/3
+	default:
+		return 0, false, false
+	}
+	return pos, ascii, pos < h.buf.Len()
+}
+
+func (h *hexEditor) Tapped(ev *fyne.PointEvent) {
+	h.focus()
+	if pos, ascii, ok := h.posAt(ev.Position); ok {
+		h.ascii = ascii
+		h.moveTo(pos, h.shift)
+	}
+}
+
+func (h *hexEditor) Dragged(ev *fyne.DragEvent) {
+	pos, ascii, ok := h.posAt(ev.Position)
+	if !ok {
+		return
+	}
+	if !h.dragging {
+		h.dragging = true
+		h.focus()
+		h.ascii = ascii
+		h.moveTo(pos, false)
+		h.anchor = pos
+		return
+	}
+	h.moveTo(pos, true)
+}
+
+func (h *hexEditor) DragEnd() {
+	h.dragging = false
+}
+
+func (h *hexEditor) FocusGained() {}
+
+func (h *hexEditor) FocusLost() {
+	h.shift = false
+}
+
+func (h *hexEditor) KeyDown(ev *fyne.KeyEvent) {
+	if ev.Name == desktop.KeyShiftLeft || ev.Name == desktop.KeyShiftRight {
+		h.shift = true
+	}
+}
+
+func (h *hexEditor) KeyUp(ev *fyne.KeyEvent) {
+	if ev.Name == desktop.KeyShiftLeft || ev.Name == desktop.KeyShiftRight {
+		h.shift = false
+	}
+}
+
+func (h *hexEditor) TypedKey(ev *fyne.KeyEvent) {
+	switch ev.Name {
+	case fyne.KeyLeft, fyne.KeyBackspace:
+		h.moveTo(h.cursor-1, h.shift)
+	case fyne.KeyRight:
+		h.moveTo(h.cursor+1, h.shift)
+	case fyne.KeyUp:
+		h.moveTo(h.cursor-hexRowWidth, h.shift)
+	case fyne.KeyDown:
+		h.moveTo(h.cursor+hexRowWidth, h.shift)
+	case fyne.KeyHome:
+		h.moveTo(h.cursor-h.cursor%hexRowWidth, h.shift)
+	case fyne.KeyEnd:
+		h.moveTo(h.cursor-h.cursor%hexRowWidth+hexRowWidth-1, h.shift)
+	case fyne.KeyPageUp:
+		h.moveTo(0, h.shift)
+	case fyne.KeyPageDown:
+		h.moveTo(h.buf.Len()-1, h.shift)
+	case fyne.KeyTab:
+		h.ascii = !h.ascii
+		h.lowNibble = false
+		h.render()
+	case fyne.KeyEscape:
+		h.moveTo(h.cursor, false)
+	}
+}
+
+func (h *hexEditor) TypedRune(r rune) {
+	if h.buf.Len() == 0 {
+		return
+	}
+	if h.ascii {
+		if r < 0x20 || r > 0x7e {
+			return
+		}
+		h.write(h.cursor, []byte{byte(r)})
+		h.moveTo(h.cursor+1, false)
+		return
+	}
+
+	d, err := strconv.ParseUint(string(r), 16, 8)
+	if err != nil {
+		return
+	}
+	old := h.buf.At(h.cursor)
+	if h.lowNibble {
+		// Both digits of a byte are one undo step
+		b := old&0xF0 | byte(d)
+		if h.nibbleStep {
+			h.amend(h.cursor, []byte{b})
+		} else {
+			h.write(h.cursor, []byte{b})
+		}
+		h.moveTo(h.cursor+1, false)
+		return
+	}
+	b := old&0x0F | byte(d)<<4
+	h.nibbleStep = b != old
+	h.write(h.cursor, []byte{b})
+	h.anchor = -1
+	h.render()
+	h.lowNibble = true
+}
+
+func (h *hexEditor) TypedShortcut(s fyne.Shortcut) {
+	switch sc := s.(type) {
+	case *fyne.ShortcutCopy:
+		h.copy()
+	case *fyne.ShortcutPaste:
+		h.paste()
+	case *fyne.ShortcutUndo:
+		h.undo()
+	case *fyne.ShortcutRedo:
+		h.redo()
+	case *fyne.ShortcutSelectAll:
+		h.anchor = 0
+		h.cursor = h.buf.Len() - 1
+		h.render()
+	case *desktop.CustomShortcut:
+		switch {
+		case sc.KeyName == fyne.KeyG && sc.Modifier == fyne.KeyModifierShortcutDefault:
+			if h.onGoTo != nil {
+				h.onGoTo()
+			}
+		case sc.KeyName == fyne.KeyZ && sc.Modifier == fyne.KeyModifierShortcutDefault|fyne.KeyModifierShift:
+			h.redo()
+		}
+	}
+}
+
+func (h *hexEditor) write(off int, p []byte) {
+	if err := h.buf.Write(off, p); err != nil {
+		fyne.LogError("hex edit", err)
+		return
+	}
+	h.onChanged(h.buf.Bytes())
+}
+
+func (h *hexEditor) amend(off int, p []byte) {
+	if err := h.buf.Amend(off, p); err != nil {
+		fyne.LogError("hex edit", err)
+		return
+	}
+	h.onChanged(h.buf.Bytes())
+}
+
+// copy puts the selection, or the byte under the cursor, on the clipboard
+// as hex.
+func (h *hexEditor) copy() {
+	sel, ok := h.selection()
+	if !ok {
+		sel = eeprom.Span{Start: h.cursor, End: h.cursor}
+	}
+	fyne.CurrentApp().Clipboard().SetContent(fmt.Sprintf("% X", h.buf.Slice(sel)))
+}
+
+// paste overwrites from the selection start, or the cursor, with hex from
+// the clipboard.
+func (h *hexEditor) paste() {
+	p, err := eeprom.ParseHex(fyne.CurrentApp().Clipboard().Content())
+	if err != nil || len(p) == 0 {
+		return
+	}
+	off := h.cursor
+	if sel, ok := h.selection(); ok {
+		off = sel.Start
+	}
+	if off+len(p) > h.buf.Len() {
+		p = p[:h.buf.Len()-off]
+	}
+	h.write(off, p)
+	h.moveTo(off+len(p)-1, false)
+}
+
+func (h *hexEditor) undo() {
+	if span, ok := h.buf.Undo(); ok {
+		h.onChanged(h.buf.Bytes())
+		h.moveTo(span.Start, false)
+	}
+}
+
+func (h *hexEditor) redo() {
+	if span, ok := h.buf.Redo(); ok {
+		h.onChanged(h.buf.Bytes())
+		h.moveTo(span.Start, false)
+	}
 }
 
-func generateGrid(data []byte) []widget.TextGridRow {
-	rowWidth := 32
+func generateGrid(data []byte, regions eeprom.Layout) []widget.TextGridRow {
+	rowWidth := hexRowWidth
 	var rows []widget.TextGridRow
 	r := bytes.NewReader(data)
 	buff := make([]byte, rowWidth)
@@ -129,13 +677,13 @@ func generateGrid(data []byte) []widget.TextGridRow {
 				widget.TextGridCell{
 					Rune: rune(hexChar[0]),
 					Style: &widget.CustomTextGridStyle{
-						FGColor: viewColor(pos),
+						FGColor: viewColor(regions, pos),
 					},
 				},
 				widget.TextGridCell{
 					Rune: rune(hexChar[1]),
 					Style: &widget.CustomTextGridStyle{
-						FGColor: viewColor(pos),
+						FGColor: viewColor(regions, pos),
 					},
 				},
 			)
@@ -183,7 +731,7 @@ func generateGrid(data []byte) []widget.TextGridRow {
 			row.Cells = append(row.Cells, widget.TextGridCell{
 				Rune: rune(bb),
 				Style: &widget.CustomTextGridStyle{
-					FGColor: viewColor(rPos),
+					FGColor: viewColor(regions, rPos),
 				},
 			})
 			rPos++
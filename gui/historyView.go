@@ -0,0 +1,151 @@
+package gui
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
+	"github.com/roffe/eep/adapter"
+	"github.com/roffe/eep/history"
+)
+
+// openHistory opens the job history in the app's storage.
+func (e *EEPGui) openHistory() (*history.Store, error) {
+	return history.Open(filepath.Join(e.Storage().RootURI().Path(), "history"))
+}
+
+// record adds an operation on the CIM to the job history. data is the image
+// read or written in file form, nil when there is none.
+func (m *mainWindow) record(op history.Op, client *adapter.Client, data []byte, err error) {
+	if m.e.history == nil {
+		return
+	}
+	entry := history.Entry{Op: op, Port: m.e.port, Firmware: client.Version(), Status: "ok"}
+	if data != nil {
+		entry.MD5, entry.CRC32 = imageHashes(data)
+		if bin, lerr := cim.LoadBytes("history.bin", bytes.Clone(data)); lerr == nil {
+			entry.VIN = bin.Vin.Data
+			entry.SnSticker = fmt.Sprintf("%X", bin.SnSticker)
+			entry.Valid = bin.Validate() == nil
+		}
+	}
+	if err != nil {
+		entry.Status = err.Error()
+	}
+	if _, err := m.e.history.Add(entry, data); err != nil {
+		m.output("Failed to record history: %v", err)
+		return
+	}
+	fyne.Do(m.history.refresh)
+}
+
+// historyView lists recorded jobs, newest first, filtered by a search.
+type historyView struct {
+	e *EEPGui
+
+	entries []history.Entry
+	search  *widget.Entry
+	list    *widget.List
+	status  *widget.Label
+}
+
+func newHistoryView(e *EEPGui) *historyView {
+	hv := &historyView{
+		e:      e,
+		search: widget.NewEntry(),
+		list:   widget.NewList(nil, nil, nil),
+		status: widget.NewLabel(""),
+	}
+	hv.search.SetPlaceHolder("Search VIN, S/N, port, date, hash or status")
+	hv.search.OnChanged = func(string) { hv.refresh() }
+
+	hv.list.Length = func() int {
+		return len(hv.entries)
+	}
+	hv.list.CreateItem = func() fyne.CanvasObject {
+		return container.NewBorder(nil, nil, nil,
+			widget.NewButtonWithIcon("Open", theme.FileIcon(), nil),
+			container.NewVBox(
+				&widget.Label{TextStyle: fyne.TextStyle{Bold: true}},
+				&widget.Label{TextStyle: fyne.TextStyle{Monospace: true}, Truncation: fyne.TextTruncateEllipsis},
+			),
+		)
+	}
+	hv.list.UpdateItem = func(item widget.ListItemID, obj fyne.CanvasObject) {
+		entry := hv.entries[item]
+		c := obj.(*fyne.Container)
+		text := c.Objects[0].(*fyne.Container)
+		title := fmt.Sprintf("%s  %s on %s", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Op, entry.Port)
+		if !entry.OK() {
+			title += " - " + entry.Status
+		}
+		text.Objects[0].(*widget.Label).SetText(title)
+		detail := fmt.Sprintf("VIN %s  S/N %s  MD5 %s  CRC32 %s  firmware %s", orNone(entry.VIN), orNone(entry.SnSticker), orNone(entry.MD5), orNone(entry.CRC32), orNone(entry.Firmware))
+		if entry.Image != "" && !entry.Valid {
+			detail += "  (invalid image)"
+		}
+		text.Objects[1].(*widget.Label).SetText(detail)
+
+		open := c.Objects[1].(*widget.Button)
+		open.OnTapped = func() { hv.open(entry) }
+		if entry.Image == "" {
+			open.Disable()
+		} else {
+			open.Enable()
+		}
+	}
+	hv.refresh()
+	return hv
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (hv *historyView) layout() fyne.CanvasObject {
+	return container.NewBorder(
+		container.NewBorder(nil, nil, widget.NewIcon(theme.SearchIcon()), nil, hv.search),
+		hv.status,
+		nil, nil,
+		hv.list,
+	)
+}
+
+func (hv *historyView) refresh() {
+	if hv.e.history == nil {
+		hv.status.SetText("History is not available")
+		return
+	}
+	entries, err := hv.e.history.List(hv.search.Text)
+	if err != nil {
+		hv.status.SetText(err.Error())
+		return
+	}
+	hv.entries = entries
+	hv.list.UnselectAll()
+	hv.list.Refresh()
+	hv.status.SetText(fmt.Sprintf("%d entries, kept in %s", len(entries), hv.e.history.Dir()))
+}
+
+// open shows the image stored with entry in a new viewer tab.
+func (hv *historyView) open(entry history.Entry) {
+	m := hv.e.mw
+	data, err := hv.e.history.Image(entry)
+	if err != nil {
+		dialog.ShowError(err, m)
+		return
+	}
+	when := entry.Time.Local().Format("2006-01-02 15:04")
+	m.docTab.Append(container.NewTabItemWithIcon(fmt.Sprintf("History %s %s", entry.Op, when), theme.FileIcon(), newViewerView(hv.e, fmt.Sprintf("%s on %s at %s", entry.Op, entry.Port, when), data, false)))
+	m.appTabs.SelectIndex(0)
+	m.docTab.SelectIndex(len(m.docTab.Items) - 1)
+}
@@ -0,0 +1,337 @@
+package gui
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
+	"github.com/roffe/eep/vin"
+)
+
+func digitsValidator(length int) fyne.StringValidator {
+	return func(s string) error {
+		if len(s) != length {
+			return errors.New("invalid length")
+		}
+		for _, c := range s {
+			if c < '0' || c > '9' {
+				return errors.New("digits only")
+			}
+		}
+		return nil
+	}
+}
+
+func vinValidator(s string) error {
+	_, err := vin.Decode(s)
+	return err
+}
+
+// infoField is one editable value on the Info tab. Input is stored in the
+// image as soon as it is complete and valid, and the field is marked while
+// the stored value differs from the saved one.
+type infoField struct {
+	vw       *viewerWindow
+	entry    *widget.Entry
+	modified *widget.Label
+
+	length int
+	// valid gates storing input, the entry's Validator only shows errors
+	valid func(string) error
+	value func(bin *cim.Bin) string
+	store func(s string)
+
+	saved   string
+	loading bool
+}
+
+func newInfoField(vw *viewerWindow, length int, valid fyne.StringValidator, value func(*cim.Bin) string, store func(string)) *infoField {
+	f := &infoField{
+		vw:       vw,
+		entry:    &widget.Entry{Wrapping: fyne.TextWrapOff, Validator: valid},
+		modified: &widget.Label{Text: "modified", TextStyle: fyne.TextStyle{Italic: true}, Importance: widget.WarningImportance},
+		length:   length,
+		valid:    valid,
+		value:    value,
+		store:    store,
+	}
+	f.entry.OnChanged = f.changed
+	return f
+}
+
+func (f *infoField) changed(s string) {
+	if f.loading {
+		return
+	}
+	if len(s) > f.length {
+		f.entry.SetText(s[:f.length])
+		return
+	}
+	if f.valid(s) != nil || strings.EqualFold(s, f.value(f.vw.cimBin)) {
+		return
+	}
+	f.store(s)
+}
+
+// load shows the value from bin without storing it back.
+func (f *infoField) load(bin *cim.Bin) {
+	f.loading = true
+	f.entry.SetText(f.value(bin))
+	f.loading = false
+	f.update(bin)
+}
+
+func (f *infoField) dirty(bin *cim.Bin) bool {
+	return !strings.EqualFold(f.value(bin), f.saved)
+}
+
+func (f *infoField) update(bin *cim.Bin) {
+	if f.dirty(bin) {
+		f.modified.Show()
+	} else {
+		f.modified.Hide()
+	}
+}
+
+func (f *infoField) row(width float32, clipboard fyne.Clipboard, extra ...fyne.CanvasObject) fyne.CanvasObject {
+	row := container.NewHBox(fixedWidth(width, f.entry))
+	row.Objects = append(row.Objects, extra...)
+	row.Objects = append(row.Objects,
+		f.modified,
+		layout.NewSpacer(),
+		widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+			clipboard.SetContent(f.entry.Text)
+		}),
+	)
+	return row
+}
+
+// infoView is the Info tab. Edits go through change so they can be undone
+// with the Keys tab's.
+type infoView struct {
+	vw *viewerWindow
+
+	md5, crc32 *widget.Label
+	modelYear  *widget.Label
+	pinHex     *widget.Label
+	sas        *widget.Select
+
+	sn, vin, pin, isk, psk *infoField
+
+	status     *widget.Label
+	undoButton *widget.Button
+	loading    bool
+}
+
+func newInfoView(vw *viewerWindow) *infoView {
+	iv := &infoView{
+		vw:        vw,
+		md5:       widget.NewLabel(""),
+		crc32:     widget.NewLabel(""),
+		modelYear: widget.NewLabel(""),
+		pinHex:    widget.NewLabel(""),
+		status:    widget.NewLabel(""),
+	}
+	iv.undoButton = widget.NewButtonWithIcon("Undo", theme.ContentUndoIcon(), vw.undoChange)
+
+	iv.sn = newInfoField(vw, 10, hexValidator(10), func(bin *cim.Bin) string {
+		return fmt.Sprintf("%X", bin.SnSticker)
+	}, func(s string) {
+		b, _ := hex.DecodeString(s)
+		iv.change("Set S/N sticker to "+strings.ToUpper(s), func(bin *cim.Bin) error {
+			bin.SnSticker = b
+			return nil
+		})
+	})
+
+	iv.vin = newInfoField(vw, vin.Length, vinValidator, func(bin *cim.Bin) string {
+		return bin.Vin.Data
+	}, vw.setVIN)
+	// A wrong check digit is shown but, after a warning, may be stored
+	iv.vin.entry.Validator = vin.Validate
+
+	iv.pin = newInfoField(vw, 4, digitsValidator(4), func(bin *cim.Bin) string {
+		if bytes.Equal(bin.Pin.Data1, []byte{0xFF, 0xFF, 0xFF, 0xFF}) {
+			return ""
+		}
+		return string(bin.Pin.Data1[:])
+	}, func(s string) {
+		iv.change("Set PIN", func(bin *cim.Bin) error {
+			return bin.SetPin(fmt.Sprintf("%X", s))
+		})
+	})
+	iv.pin.entry.SetPlaceHolder("not set")
+
+	iv.isk = newInfoField(vw, 12, hexValidator(12), func(bin *cim.Bin) string {
+		return fmt.Sprintf("%X%X", bin.Keys.IskHI1, bin.Keys.IskLO1)
+	}, func(s string) {
+		iv.change("Set ISK to "+strings.ToUpper(s), func(bin *cim.Bin) error {
+			if err := bin.Keys.SetISKHigh(s[:8]); err != nil {
+				return err
+			}
+			return bin.Keys.SetISKLow(s[8:12])
+		})
+	})
+
+	iv.psk = newInfoField(vw, 12, hexValidator(12), func(bin *cim.Bin) string {
+		return fmt.Sprintf("%X%X", bin.PSK.High, bin.PSK.Low)
+	}, func(s string) {
+		decoded, _ := hex.DecodeString(s)
+		iv.change("Set PSK to "+strings.ToUpper(s), func(bin *cim.Bin) error {
+			if err := bin.PSK.SetHigh(decoded[:4]); err != nil {
+				return err
+			}
+			return bin.PSK.SetLow(decoded[4:6])
+		})
+	})
+
+	iv.sas = widget.NewSelect([]string{"Yes", "No"}, func(s string) {
+		if iv.loading {
+			return
+		}
+		iv.change("Set SAS option to "+s, func(bin *cim.Bin) error {
+			bin.SetSasOpt(s == "Yes")
+			return nil
+		})
+	})
+
+	iv.markSaved()
+	iv.reload()
+	return iv
+}
+
+func (iv *infoView) fields() []*infoField {
+	return []*infoField{iv.sn, iv.vin, iv.pin, iv.isk, iv.psk}
+}
+
+func (iv *infoView) layout() fyne.CanvasObject {
+	clipboard := iv.vw.e.App.Clipboard()
+	copyLabel := func(l *widget.Label) fyne.CanvasObject {
+		return container.NewHBox(
+			l,
+			layout.NewSpacer(),
+			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+				clipboard.SetContent(l.Text)
+			}),
+		)
+	}
+	vButton := widget.NewButtonWithIcon("Virginize", theme.SearchReplaceIcon(), func() {
+		if iv.change("Virginized the CIM", func(bin *cim.Bin) error {
+			bin.Unmarry()
+			return nil
+		}) {
+			iv.vw.refreshTabs()
+			dialog.ShowInformation("Virginization complete", "The file has been virginized.\nNow flash the eeprom, re-assemble the car and add the CIM with Tech2", iv.vw)
+		}
+	})
+
+	form := widget.NewForm(
+		widget.NewFormItem("MD5", copyLabel(iv.md5)),
+		widget.NewFormItem("CRC32", copyLabel(iv.crc32)),
+		widget.NewFormItem("S/N Sticker", iv.sn.row(100, clipboard)),
+		widget.NewFormItem("VIN", iv.vin.row(150, clipboard, container.NewHBox(
+			widget.NewLabelWithStyle("MY:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			iv.modelYear,
+		))),
+		widget.NewFormItem("PIN", iv.pin.row(100, clipboard)),
+		widget.NewFormItem("PIN (hex)", copyLabel(iv.pinHex)),
+		widget.NewFormItem("SAS", iv.sas),
+		widget.NewFormItem("ISK", iv.isk.row(150, clipboard)),
+		widget.NewFormItem("PSK", iv.psk.row(150, clipboard)),
+	)
+	return container.NewBorder(nil, container.NewBorder(nil, nil, vButton, iv.undoButton, iv.status), nil, nil, form)
+}
+
+// reload shows the values from vw.cimBin, after the image was replaced.
+func (iv *infoView) reload() {
+	bin := iv.vw.cimBin
+	for _, f := range iv.fields() {
+		f.load(bin)
+	}
+	iv.loading = true
+	if bin.GetSasOpt() {
+		iv.sas.SetSelected("Yes")
+	} else {
+		iv.sas.SetSelected("No")
+	}
+	iv.loading = false
+	iv.refresh()
+}
+
+// refresh updates everything but the entries, which may be being typed in.
+func (iv *infoView) refresh() {
+	bin := iv.vw.cimBin
+	iv.md5.SetText(bin.MD5())
+	iv.crc32.SetText(bin.CRC32())
+	iv.modelYear.SetText(bin.ModelYear())
+	iv.pinHex.SetText(fmt.Sprintf("%X", bin.Pin.Data1))
+
+	modified := 0
+	for _, f := range iv.fields() {
+		f.update(bin)
+		if f.dirty(bin) {
+			modified++
+		}
+	}
+	if modified > 0 {
+		iv.status.SetText(fmt.Sprintf("%d unsaved field(s)", modified))
+	} else {
+		iv.status.SetText("")
+	}
+	if len(iv.vw.undo) > 0 {
+		iv.undoButton.Enable()
+	} else {
+		iv.undoButton.Disable()
+	}
+}
+
+// markSaved takes the current values as the saved ones.
+func (iv *infoView) markSaved() {
+	for _, f := range iv.fields() {
+		f.saved = f.value(iv.vw.cimBin)
+	}
+	iv.refresh()
+}
+
+// change runs f against vw.cimBin and records it for undo. The Info tab is
+// not rebuilt so the entry being typed in keeps its focus.
+func (iv *infoView) change(desc string, f func(bin *cim.Bin) error) bool {
+	vw := iv.vw
+	if err := vw.editable(); err != nil {
+		iv.reload()
+		dialog.ShowError(err, vw)
+		return false
+	}
+	before, err := vw.cimBin.XORBytes()
+	if err != nil {
+		dialog.ShowError(err, vw)
+		return false
+	}
+	if err := f(vw.cimBin); err != nil {
+		vw.restore(before)
+		dialog.ShowError(fmt.Errorf("%s: %w", desc, err), vw)
+		return false
+	}
+	if err := vw.commit(desc, before); err != nil {
+		vw.restore(before)
+		dialog.ShowError(err, vw)
+		return false
+	}
+	vw.vinTab.Content = vw.renderVinTab()
+	vw.hexEditor.Reload(vw.data)
+	vw.checks.reload()
+	vw.mirrors.reload()
+	vw.keys.refresh()
+	iv.refresh()
+	vw.tabs.Refresh()
+	return true
+}
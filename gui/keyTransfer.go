@@ -0,0 +1,273 @@
+package gui
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
+	sdialog "github.com/sqweek/dialog"
+)
+
+// keyFile is the JSON form keys are exported in. All values are hex.
+type keyFile struct {
+	Source   string     `json:"source,omitempty"`
+	Exported time.Time  `json:"exported"`
+	ISKHigh  string     `json:"isk_high"`
+	ISKLow   string     `json:"isk_low"`
+	PSKHigh  string     `json:"psk_high"`
+	PSKLow   string     `json:"psk_low"`
+	Keys     []keyEntry `json:"keys"`
+}
+
+type keyEntry struct {
+	IDE  string `json:"ide"`
+	Sync string `json:"sync"`
+	Type string `json:"type,omitempty"`
+}
+
+// syncLen is the size of a key's sync data.
+const syncLen = 4
+
+// decode returns the IDE and sync data of k, checking their lengths.
+func (k keyEntry) decode() (ide, sync []byte, err error) {
+	ide, err = hex.DecodeString(k.IDE)
+	if err == nil && len(ide) != keyIDLen {
+		err = fmt.Errorf("%d bytes, expected %d", len(ide), keyIDLen)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("IDE %q: %w", k.IDE, err)
+	}
+	sync, err = hex.DecodeString(k.Sync)
+	if err == nil && len(sync) != syncLen {
+		err = fmt.Errorf("%d bytes, expected %d", len(sync), syncLen)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("sync data %q: %w", k.Sync, err)
+	}
+	return ide, sync, nil
+}
+
+// keysOf collects the learned keys and secret keys of bin.
+func keysOf(bin *cim.Bin, source string) *keyFile {
+	kf := &keyFile{
+		Source:   source,
+		Exported: time.Now(),
+		ISKHigh:  fmt.Sprintf("%X", bin.Keys.IskHI1),
+		ISKLow:   fmt.Sprintf("%X", bin.Keys.IskLO1),
+		PSKHigh:  fmt.Sprintf("%X", bin.PSK.High),
+		PSKLow:   fmt.Sprintf("%X", bin.PSK.Low),
+		Keys:     []keyEntry{},
+	}
+	for i := range min(int(bin.Keys.Count1), len(bin.Keys.Data1)) {
+		entry := keyEntry{
+			IDE:  fmt.Sprintf("%X", bin.Keys.Data1[i].Value),
+			Type: bin.Keys.Data1[i].Type(),
+		}
+		if i < len(bin.Sync.Data) {
+			entry.Sync = fmt.Sprintf("%X", bin.Sync.Data[i])
+		}
+		kf.Keys = append(kf.Keys, entry)
+	}
+	return kf
+}
+
+// loadKeys reads keys from a CIM dump or a key file written by exportKeys.
+func loadKeys(filename string) (*keyFile, error) {
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		kf := &keyFile{}
+		if err := json.Unmarshal(b, kf); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(filename), err)
+		}
+		return kf, nil
+	}
+	bin, err := cim.MustLoad(filename)
+	if err != nil {
+		return nil, err
+	}
+	return keysOf(bin, filepath.Base(filename)), nil
+}
+
+func (ks *keysView) exportKeys() {
+	vw := ks.vw
+	b, err := json.MarshalIndent(keysOf(vw.cimBin, filepath.Base(vw.filename)), "", "  ")
+	if err != nil {
+		dialog.ShowError(err, vw)
+		return
+	}
+	go func() {
+		filename, err := sdialog.File().Filter("Key file", "json").SetStartFile(fmt.Sprintf("keys_%x.json", vw.cimBin.SnSticker)).Title("Export keys").Save()
+		if err != nil {
+			if err.Error() != "Cancelled" {
+				vw.e.mw.output("%s", err)
+			}
+			return
+		}
+		filename = addSuffix(filename, ".json")
+		if err := os.WriteFile(filename, b, 0600); err != nil {
+			fyne.Do(func() { dialog.ShowError(err, vw) })
+			return
+		}
+		vw.output("Exported %d key(s) to %s", int(vw.cimBin.Keys.Count1), filename)
+	}()
+}
+
+func (ks *keysView) importKeys() {
+	go func() {
+		filename, err := sdialog.File().Filter("Bin file", "bin").Filter("Key file", "json").Title("Import keys from").Load()
+		if err != nil {
+			if err.Error() != "Cancelled" {
+				ks.vw.e.mw.output("%s", err)
+			}
+			return
+		}
+		kf, err := loadKeys(filename)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, ks.vw)
+				return
+			}
+			if kf.Source == "" {
+				kf.Source = filepath.Base(filename)
+			}
+			ks.chooseImport(kf)
+		})
+	}()
+}
+
+// chooseImport lets the user pick which keys of kf to add.
+func (ks *keysView) chooseImport(kf *keyFile) {
+	var checks []*widget.Check
+	rows := container.NewVBox()
+	seen := make(map[string]int)
+	for i, k := range kf.Keys {
+		label := fmt.Sprintf("#%d  IDE %s  sync %s  %s", i, k.IDE, k.Sync, k.Type)
+		check := widget.NewCheck(label, nil)
+		first, dup := seen[strings.ToUpper(k.IDE)]
+		if !dup {
+			seen[strings.ToUpper(k.IDE)] = i
+		}
+		if _, _, err := k.decode(); err != nil {
+			check.Text += fmt.Sprintf("  (%v)", err)
+			check.Disable()
+		} else if dup {
+			check.Text += fmt.Sprintf("  (same IDE as #%d in the file)", first)
+			check.Disable()
+		} else if j := ks.indexOf(k.IDE); j >= 0 {
+			check.Text += fmt.Sprintf("  (already key #%d)", j)
+			check.Disable()
+		} else {
+			check.SetChecked(true)
+		}
+		checks = append(checks, check)
+		rows.Add(check)
+	}
+	if len(kf.Keys) == 0 {
+		rows.Add(widget.NewLabel("The file has no keys"))
+	}
+	isk := widget.NewCheck(fmt.Sprintf("Also copy ISK (%s%s)", kf.ISKHigh, kf.ISKLow), nil)
+	psk := widget.NewCheck(fmt.Sprintf("Also copy PSK (%s%s)", kf.PSKHigh, kf.PSKLow), nil)
+
+	free := ks.slots() - ks.count()
+	content := container.NewBorder(
+		widget.NewLabel(fmt.Sprintf("Keys in %s, %d free slot(s) here", kf.Source, free)),
+		container.NewVBox(widget.NewSeparator(), isk, psk),
+		nil, nil,
+		container.NewVScroll(rows),
+	)
+	d := dialog.NewCustomConfirm("Import keys", "Import", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		var keys []keyEntry
+		for i, c := range checks {
+			if c.Checked && !c.Disabled() {
+				keys = append(keys, kf.Keys[i])
+			}
+		}
+		if len(keys) > free {
+			dialog.ShowError(fmt.Errorf("%d key(s) selected but only %d slot(s) are free", len(keys), free), ks.vw)
+			return
+		}
+		if len(keys) == 0 && !isk.Checked && !psk.Checked {
+			return
+		}
+		ks.change(fmt.Sprintf("Imported %d key(s) from %s", len(keys), kf.Source), func(bin *cim.Bin) error {
+			return importKeys(bin, kf, keys, isk.Checked, psk.Checked)
+		})
+	}, ks.vw)
+	d.Resize(fyne.NewSize(560, 400))
+	d.Show()
+}
+
+// importKeys appends keys to bin after its current keys, and copies the ISK
+// and PSK of kf when asked to. Nothing is changed if a key is malformed or
+// its IDE is given twice.
+func importKeys(bin *cim.Bin, kf *keyFile, keys []keyEntry, withISK, withPSK bool) error {
+	ides := make([][]byte, len(keys))
+	syncs := make([][]byte, len(keys))
+	for i, k := range keys {
+		ide, sync, err := k.decode()
+		if err != nil {
+			return err
+		}
+		for _, prev := range ides[:i] {
+			if bytes.Equal(prev, ide) {
+				return fmt.Errorf("IDE %X is in the import twice", ide)
+			}
+		}
+		ides[i], syncs[i] = ide, sync
+	}
+	slot := int(bin.Keys.Count1)
+	for i, ide := range ides {
+		sync := syncs[i]
+		if err := bin.SetKeyID(uint8(slot), ide); err != nil {
+			return err
+		}
+		if err := bin.SetSyncData(uint8(slot), sync); err != nil {
+			return err
+		}
+		slot++
+	}
+	if err := bin.SetKeyCount(uint8(slot)); err != nil {
+		return err
+	}
+	if withISK {
+		if err := bin.Keys.SetISKHigh(kf.ISKHigh); err != nil {
+			return err
+		}
+		if err := bin.Keys.SetISKLow(kf.ISKLow); err != nil {
+			return err
+		}
+	}
+	if withPSK {
+		high, err := hex.DecodeString(kf.PSKHigh)
+		if err != nil {
+			return fmt.Errorf("PSK %q: %w", kf.PSKHigh, err)
+		}
+		low, err := hex.DecodeString(kf.PSKLow)
+		if err != nil {
+			return fmt.Errorf("PSK %q: %w", kf.PSKLow, err)
+		}
+		if err := bin.PSK.SetHigh(high); err != nil {
+			return err
+		}
+		if err := bin.PSK.SetLow(low); err != nil {
+			return err
+		}
+	}
+	return nil
+}
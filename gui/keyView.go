@@ -28,10 +28,13 @@ func newKeyView(e *EEPGui, vw *viewerWindow, index int, fw *cim.Bin) fyne.Canvas
 				dialog.ShowError(err, vw)
 				return
 			}
-			if err := fw.SetKeyID(uint8(index), b); err != nil {
-				dialog.ShowError(err, vw)
+			if i := vw.keys.indexOf(s); i >= 0 && i != index {
+				dialog.ShowError(fmt.Errorf("IDE %X is already used by key #%d", b, i), vw)
 				return
 			}
+			vw.keys.change(fmt.Sprintf("Set key #%d IDE to %X", index, b), func(bin *cim.Bin) error {
+				return bin.SetKeyID(uint8(index), b)
+			})
 		}
 	}
 
@@ -46,10 +49,9 @@ func newKeyView(e *EEPGui, vw *viewerWindow, index int, fw *cim.Bin) fyne.Canvas
 		if len(s) == 8 {
 			b, err := hex.DecodeString(s)
 			if err == nil {
-				if err := fw.SetSyncData(uint8(index), b); err != nil {
-					dialog.ShowError(err, vw)
-					return
-				}
+				vw.keys.change(fmt.Sprintf("Set key #%d sync data to %X", index, b), func(bin *cim.Bin) error {
+					return bin.SetSyncData(uint8(index), b)
+				})
 			}
 		}
 	}
@@ -0,0 +1,292 @@
+package gui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
+)
+
+// keysView is the Keys tab. Every key change is made on vw.cimBin, checked
+// for consistent key counts and recorded so it can be undone.
+type keysView struct {
+	vw *viewerWindow
+
+	list       *widget.List
+	status     *widget.Label
+	undoButton *widget.Button
+	addButton  *widget.Button
+}
+
+func newKeysView(vw *viewerWindow) *keysView {
+	ks := &keysView{
+		vw:     vw,
+		status: widget.NewLabel(""),
+	}
+	ks.undoButton = widget.NewButtonWithIcon("Undo", theme.ContentUndoIcon(), vw.undoChange)
+	ks.addButton = widget.NewButtonWithIcon("Add key", theme.ContentAddIcon(), vw.addKey)
+	ks.list = &widget.List{
+		Length: ks.slots,
+		CreateItem: func() fyne.CanvasObject {
+			return container.NewHBox(
+				&widget.Label{TextStyle: fyne.TextStyle{Bold: true}},
+				layout.NewSpacer(),
+				&widget.Label{TextStyle: fyne.TextStyle{Italic: true}},
+				&widget.Label{},
+				layout.NewSpacer(),
+				widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {}),
+				widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() {}),
+				widget.NewButtonWithIcon("Show", theme.HelpIcon(), func() {}),
+				widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {}),
+			)
+		},
+		UpdateItem: func(item widget.ListItemID, obj fyne.CanvasObject) {
+			c := obj.(*fyne.Container)
+			buttons := c.Objects[5:]
+			if item >= ks.count() {
+				c.Objects[0].(*widget.Label).SetText(fmt.Sprintf("Slot #%d", item))
+				c.Objects[2].(*widget.Label).SetText("")
+				c.Objects[3].(*widget.Label).SetText("free")
+				for _, b := range buttons {
+					b.Hide()
+				}
+				return
+			}
+			for _, b := range buttons {
+				b.Show()
+			}
+			key := vw.cimBin.Keys.Data1[item]
+			c.Objects[0].(*widget.Label).SetText(fmt.Sprintf("Key #%d", item))
+			c.Objects[2].(*widget.Label).SetText(key.Type())
+			c.Objects[3].(*widget.Label).SetText(fmt.Sprintf("%X", key.Value))
+
+			up, down := c.Objects[5].(*widget.Button), c.Objects[6].(*widget.Button)
+			up.OnTapped = func() { ks.move(item, item-1) }
+			down.OnTapped = func() { ks.move(item, item+1) }
+			if item == 0 {
+				up.Disable()
+			} else {
+				up.Enable()
+			}
+			if item == ks.count()-1 {
+				down.Disable()
+			} else {
+				down.Enable()
+			}
+			c.Objects[7].(*widget.Button).OnTapped = func() {
+				dialog.ShowCustom(fmt.Sprintf("Key #%d", item), "OK", newKeyView(vw.e, vw, item, vw.cimBin), vw)
+			}
+			c.Objects[8].(*widget.Button).OnTapped = func() {
+				ks.confirmDelete(item)
+			}
+		},
+	}
+	ks.refresh()
+	return ks
+}
+
+func (ks *keysView) layout() fyne.CanvasObject {
+	return container.NewBorder(
+		nil,
+		container.NewBorder(nil, nil, nil,
+			container.NewHBox(
+				ks.undoButton,
+				widget.NewButtonWithIcon("Import keys from...", theme.DownloadIcon(), ks.importKeys),
+				widget.NewButtonWithIcon("Export keys", theme.DocumentSaveIcon(), ks.exportKeys),
+				ks.addButton,
+			),
+			ks.status,
+		),
+		nil,
+		nil,
+		ks.list,
+	)
+}
+
+// count is the number of keys the list can show, never more than there are
+// key slots even when the stored count is corrupt.
+func (ks *keysView) count() int {
+	return min(int(ks.vw.cimBin.Keys.Count1), len(ks.vw.cimBin.Keys.Data1))
+}
+
+// keyIDLen is the size of a key's IDE in the KEYS Data region.
+const keyIDLen = 4
+
+// slots is the number of key slots, from the size of the KEYS Data region
+// of the layout, or the key table when the layout has no such region.
+func (ks *keysView) slots() int {
+	slots := len(ks.vw.cimBin.Keys.Data1)
+	if r, ok := ks.vw.regionMap.Regions.Find("KEYS Data #1"); ok {
+		slots = min(slots, r.Len()/keyIDLen)
+	}
+	return max(slots, ks.count())
+}
+
+// indexOf returns the slot holding the key with IDE id, in hex, or -1.
+func (ks *keysView) indexOf(id string) int {
+	for i := range ks.count() {
+		if strings.EqualFold(fmt.Sprintf("%X", ks.vw.cimBin.Keys.Data1[i].Value), id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// countProblems checks the stored key counts against each other and against
+// the number of key and sync data slots.
+func countProblems(bin *cim.Bin) []string {
+	var problems []string
+	keys := &bin.Keys
+	if keys.Count1 != keys.Count2 {
+		problems = append(problems, fmt.Sprintf("key counts disagree (%d and %d)", keys.Count1, keys.Count2))
+	}
+	if int(keys.Count1) > len(keys.Data1) {
+		problems = append(problems, fmt.Sprintf("key count %d exceeds the %d key slots", keys.Count1, len(keys.Data1)))
+	}
+	if int(keys.Count1) > len(bin.Sync.Data) {
+		problems = append(problems, fmt.Sprintf("key count %d exceeds the %d sync data slots", keys.Count1, len(bin.Sync.Data)))
+	}
+	return problems
+}
+
+func (ks *keysView) refresh() {
+	if problems := countProblems(ks.vw.cimBin); len(problems) > 0 {
+		ks.status.SetText("Warning: " + strings.Join(problems, ", "))
+	} else {
+		ks.status.SetText(fmt.Sprintf("%d key(s), %d of %d slots free", ks.count(), ks.slots()-ks.count(), ks.slots()))
+	}
+	if ks.count() < ks.slots() {
+		ks.addButton.Enable()
+	} else {
+		ks.addButton.Disable()
+	}
+	if len(ks.vw.undo) > 0 {
+		ks.undoButton.Enable()
+	} else {
+		ks.undoButton.Disable()
+	}
+	ks.list.Refresh()
+}
+
+// change runs f against vw.cimBin. If f fails the image is restored,
+// otherwise the change is recorded for undo and the document marked unsaved.
+func (ks *keysView) change(desc string, f func(bin *cim.Bin) error) {
+	vw := ks.vw
+	if err := vw.editable(); err != nil {
+		dialog.ShowError(err, vw)
+		return
+	}
+	before, err := vw.cimBin.XORBytes()
+	if err != nil {
+		dialog.ShowError(err, vw)
+		return
+	}
+	consistent := len(countProblems(vw.cimBin)) == 0
+	if err := f(vw.cimBin); err != nil {
+		vw.restore(before)
+		dialog.ShowError(fmt.Errorf("%s: %w", desc, err), vw)
+		return
+	}
+	// Changes to an image that was already inconsistent are let through so
+	// it can be repaired, the status line keeps warning about it
+	if problems := countProblems(vw.cimBin); consistent && len(problems) > 0 {
+		vw.restore(before)
+		dialog.ShowError(fmt.Errorf("%s left the image inconsistent: %s", desc, strings.Join(problems, ", ")), vw)
+		return
+	}
+	if err := vw.commit(desc, before); err != nil {
+		vw.restore(before)
+		dialog.ShowError(err, vw)
+		return
+	}
+	vw.refreshTabs()
+}
+
+func (ks *keysView) confirmDelete(item int) {
+	keys := &ks.vw.cimBin.Keys
+	msg := fmt.Sprintf("Delete key #%d (%X)?", item, keys.Data1[item].Value)
+	if item < ks.count()-1 {
+		msg += "\nThe keys after it move up one slot, with their sync data."
+	}
+	if problems := countProblems(ks.vw.cimBin); len(problems) > 0 {
+		msg += "\n\nWarning: " + strings.Join(problems, ", ") + "."
+	}
+	dialog.ShowConfirm("Delete key", msg, func(ok bool) {
+		if ok {
+			ks.change(fmt.Sprintf("Deleted key #%d", item), func(bin *cim.Bin) error {
+				return deleteKey(bin, item)
+			})
+		}
+	}, ks.vw)
+}
+
+// deleteKey removes key item and moves the sync data of the keys after it
+// along with them. The freed sync data slot is cleared.
+func deleteKey(bin *cim.Bin, item int) error {
+	count := int(bin.Keys.Count1)
+	if item < 0 || item >= count {
+		return fmt.Errorf("no key #%d, there are %d keys", item, count)
+	}
+	sync := make([][]byte, len(bin.Sync.Data))
+	for i, s := range bin.Sync.Data {
+		sync[i] = bytes.Clone(s)
+	}
+
+	if err := bin.DeleteKey(item); err != nil {
+		return err
+	}
+	// DeleteKey maintains the counts, only repair them if it didn't
+	want := uint8(count - 1)
+	if bin.Keys.Count1 != want || bin.Keys.Count2 != want {
+		if err := bin.SetKeyCount(want); err != nil {
+			return err
+		}
+	}
+
+	for i := item; i < count-1 && i+1 < len(sync); i++ {
+		if err := bin.SetSyncData(uint8(i), sync[i+1]); err != nil {
+			return err
+		}
+	}
+	if last := count - 1; last < len(sync) {
+		if err := bin.SetSyncData(uint8(last), bytes.Repeat([]byte{0xFF}, len(sync[last]))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// move swaps key from with key to, taking their sync data along.
+func (ks *keysView) move(from, to int) {
+	if to < 0 || to >= ks.count() {
+		return
+	}
+	ks.change(fmt.Sprintf("Moved key #%d to slot #%d", from, to), func(bin *cim.Bin) error {
+		return swapKeys(bin, from, to)
+	})
+}
+
+func swapKeys(bin *cim.Bin, i, j int) error {
+	if max(i, j) >= len(bin.Sync.Data) {
+		return fmt.Errorf("no sync data for slot #%d", max(i, j))
+	}
+	idI, idJ := bytes.Clone(bin.Keys.Data1[i].Value), bytes.Clone(bin.Keys.Data1[j].Value)
+	syncI, syncJ := bytes.Clone(bin.Sync.Data[i]), bytes.Clone(bin.Sync.Data[j])
+	if err := bin.SetKeyID(uint8(i), idJ); err != nil {
+		return err
+	}
+	if err := bin.SetKeyID(uint8(j), idI); err != nil {
+		return err
+	}
+	if err := bin.SetSyncData(uint8(i), syncJ); err != nil {
+		return err
+	}
+	return bin.SetSyncData(uint8(j), syncI)
+}
@@ -0,0 +1,123 @@
+package gui
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/eep/eeprom"
+	sdialog "github.com/sqweek/dialog"
+)
+
+// prefLayoutFiles remembers community layout files the user has loaded.
+const prefLayoutFiles = "layout_files"
+
+// layouts returns the built in layouts followed by the remembered community
+// layouts that still load. Load errors are logged.
+func (e *EEPGui) layouts() []*eeprom.Definition {
+	defs := eeprom.Builtin()
+	for _, filename := range e.Preferences().StringList(prefLayoutFiles) {
+		d, err := eeprom.Load(filename)
+		if err != nil {
+			e.mw.output("Layout %s: %v", filename, err)
+			continue
+		}
+		defs = append(defs, d)
+	}
+	return defs
+}
+
+func (e *EEPGui) rememberLayout(filename string) {
+	files := e.Preferences().StringList(prefLayoutFiles)
+	if !slices.Contains(files, filename) {
+		e.Preferences().SetStringList(prefLayoutFiles, append(files, filename))
+	}
+}
+
+func layoutTitle(d *eeprom.Definition) string {
+	title := fmt.Sprintf("%s (%d bytes)", d.Name, d.Size)
+	if d.Source != "" {
+		title += " - " + filepath.Base(d.Source)
+	}
+	return title
+}
+
+// chooseLayout lets the user pick the region map the viewer colours and edits
+// with, from the built in layouts, remembered ones, or a new file.
+func (vw *viewerWindow) chooseLayout() {
+	defs := vw.e.layouts()
+	var titles []string
+	for _, d := range defs {
+		titles = append(titles, layoutTitle(d))
+	}
+
+	var d *dialog.ConfirmDialog
+	choice := widget.NewRadioGroup(titles, nil)
+	choice.SetSelected(layoutTitle(vw.regionMap))
+	loadButton := widget.NewButtonWithIcon("Load layout file...", theme.FolderOpenIcon(), func() {
+		d.Hide()
+		go vw.loadLayoutFile()
+	})
+	content := container.NewBorder(
+		widget.NewLabel(fmt.Sprintf("Region map for this %d byte image", len(vw.data))),
+		loadButton,
+		nil,
+		nil,
+		container.NewVScroll(choice),
+	)
+	d = dialog.NewCustomConfirm("Layout", "Use", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		i := slices.Index(titles, choice.Selected)
+		if i < 0 {
+			return
+		}
+		vw.setLayout(defs[i])
+	}, vw)
+	d.Resize(fyne.NewSize(420, 320))
+	d.Show()
+}
+
+func (vw *viewerWindow) loadLayoutFile() {
+	filename, err := sdialog.File().Filter("Layout", "json").Title("Load layout").Load()
+	if err != nil {
+		if err.Error() != "Cancelled" {
+			vw.e.mw.output("%s", err)
+		}
+		return
+	}
+	def, err := eeprom.Load(filename)
+	if err != nil {
+		fyne.Do(func() { dialog.ShowError(err, vw) })
+		return
+	}
+	vw.e.rememberLayout(filename)
+	fyne.Do(func() { vw.setLayout(def) })
+}
+
+// setLayout switches the viewer to another region map. Layouts for a
+// different size still load, but a warning is shown.
+func (vw *viewerWindow) setLayout(def *eeprom.Definition) {
+	if def.Size != len(vw.data) {
+		dialog.ShowInformation("Layout", fmt.Sprintf("%s describes %d bytes, this image is %d bytes.\nRegions outside the image are ignored.", def.Name, def.Size, len(vw.data)), vw)
+	}
+	for _, w := range def.Warnings {
+		vw.output("Layout %s: %s", def.Name, w)
+	}
+	vw.output("Using layout %s", layoutTitle(def))
+	vw.regionMap = def
+	if vw.hexEditor != nil {
+		vw.hexEditor.SetLayout(def.Regions)
+	}
+	if vw.editor != nil {
+		vw.editor.setLayout(def.Regions)
+	}
+	vw.checks.reload()
+	vw.mirrors.reload()
+}
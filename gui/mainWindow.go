@@ -19,6 +19,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/roffe/cim/pkg/cim"
 	"github.com/roffe/eep/adapter"
+	"github.com/roffe/eep/history"
 	sdialog "github.com/sqweek/dialog"
 )
 
@@ -35,20 +36,26 @@ type mainWindow struct {
 	rescanButton *widget.Button
 	portList     *widget.Select
 
-	openButton     *widget.Button
-	readButton     *widget.Button
-	writeButton    *widget.Button
-	eraseButton    *widget.Button
-	helpButton     *widget.Button
-	copyButton     *widget.Button
-	clearButton    *widget.Button
-	settingsButton *widget.Button
+	openButton       *widget.Button
+	readButton       *widget.Button
+	writeButton      *widget.Button
+	eraseButton      *widget.Button
+	transplantButton *widget.Button
+	helpButton       *widget.Button
+	copyButton       *widget.Button
+	clearButton      *widget.Button
+	settingsButton   *widget.Button
 
 	readMIUButton  *widget.Button
 	writeMIUButton *widget.Button
 
 	progressBar *widget.ProgressBar
 
+	history *historyView
+
+	// viewers maps open doc tab contents to their viewer
+	viewers map[fyne.CanvasObject]*viewerWindow
+
 	fyne.Window
 }
 
@@ -60,6 +67,7 @@ func newMainWindow(e *EEPGui) *mainWindow {
 		e:           e,
 		logList:     binding.NewStringList(),
 		progressBar: widget.NewProgressBar(),
+		viewers:     make(map[fyne.CanvasObject]*viewerWindow),
 	}
 
 	m.docTab = container.NewDocTabs()
@@ -70,6 +78,7 @@ func newMainWindow(e *EEPGui) *mainWindow {
 		dialog.ShowConfirm("Close", "Are you sure you want to close this tab?", func(b bool) {
 			if b {
 				m.docTab.Remove(i)
+				delete(m.viewers, i.Content)
 			}
 		}, m.Window)
 	}
@@ -130,6 +139,7 @@ func newMainWindow(e *EEPGui) *mainWindow {
 	m.readButton = widget.NewButtonWithIcon("Read", theme.DownloadIcon(), m.readClickHandler)
 	m.writeButton = widget.NewButtonWithIcon("Write", theme.UploadIcon(), m.writeClickHandler)
 	m.eraseButton = widget.NewButtonWithIcon("Erase", theme.DeleteIcon(), m.eraseClickHandler)
+	m.transplantButton = widget.NewButtonWithIcon("Transplant", theme.ContentCopyIcon(), m.e.transplantWizard)
 	m.helpButton = widget.NewButtonWithIcon("Help", theme.HelpIcon(), func() {
 		if m.hw == nil {
 			m.hw = newHelpWindow(e)
@@ -187,11 +197,14 @@ func newMainWindow(e *EEPGui) *mainWindow {
 
 func (m *mainWindow) layout() fyne.CanvasObject {
 
+	m.history = newHistoryView(m.e)
 	m.appTabs = container.NewAppTabs(
 		container.NewTabItemWithIcon("Home", theme.HomeIcon(),
 			m.docTab,
 		),
 		container.NewTabItemWithIcon("Log", theme.DocumentIcon(), m.log),
+		container.NewTabItemWithIcon("Compare", theme.ViewRestoreIcon(), newCompareView(m.e)),
+		container.NewTabItemWithIcon("History", theme.HistoryIcon(), m.history.layout()),
 		//container.NewTabItemWithIcon("Help", theme.HelpIcon(), newHelpView(m.e)),
 		container.NewTabItemWithIcon("About", theme.InfoIcon(), aboutView(m.e)),
 		container.NewTabItemWithIcon("Settings", theme.SettingsIcon(), newSettingsView(m.e)),
@@ -208,6 +221,7 @@ func (m *mainWindow) layout() fyne.CanvasObject {
 			m.readButton,
 			m.writeButton,
 			m.eraseButton,
+			m.transplantButton,
 			layout.NewSpacer(),
 			//m.readMIUButton,
 			//m.writeMIUButton,
@@ -242,14 +256,18 @@ func (m *mainWindow) viewClickHandler() {
 
 		bin, err := cim.MustLoad(filename)
 		if err != nil {
+			rawbin, rerr := os.ReadFile(filename)
+			if rerr != nil {
+				fyne.Do(func() { dialog.ShowError(rerr, m) })
+				return
+			}
+			msg := fmt.Sprintf("File verification failed: %v.", err)
+			if summary := checkSummary(rawbin); summary != "" {
+				msg += "\n" + summary + "."
+			}
 			fyne.Do(func() {
-				dialog.ShowConfirm("File verification failed", fmt.Sprintf("File verification failed: %v. View anyway?", err), func(ok bool) {
+				dialog.ShowConfirm("File verification failed", msg+"\nView anyway?", func(ok bool) {
 					if ok {
-						rawbin, err := os.ReadFile(filename)
-						if err != nil {
-							dialog.ShowError(err, m)
-							return
-						}
 						m.docTab.Append(container.NewTabItemWithIcon(filepath.Base(filename), theme.FileIcon(), newViewerView(m.e, filename, rawbin, false)))
 						m.appTabs.SelectIndex(0)
 						m.docTab.SelectIndex(len(m.docTab.Items) - 1)
@@ -292,7 +310,11 @@ func (m *mainWindow) readClickHandler() {
 			} else {
 				fyne.Do(func() {
 					m.appTabs.SelectIndex(1)
-					dialog.ShowConfirm("Error reading CIM", "There was errors reading, view anyway?", func(ok bool) {
+					msg := "There was errors reading."
+					if summary := checkSummary(rawBytes); summary != "" {
+						msg += "\n" + summary + "."
+					}
+					dialog.ShowConfirm("Error reading CIM", msg+"\nView anyway?", func(ok bool) {
 						if ok {
 							m.docTab.Append(container.NewTabItemWithIcon(fmt.Sprintf("Raw read at %s", time.Now().Format("15:04:05")), theme.FileIcon(), newViewerView(m.e, fmt.Sprintf("failed read from %s", time.Now().Format(time.RFC1123Z)), rawBytes, true)))
 							m.appTabs.SelectIndex(0)
@@ -337,7 +359,7 @@ func (m *mainWindow) writeClickHandler() {
 			go func() {
 				m.disableButtons()
 				defer m.enableButtons()
-				if err := m.writeCIM(m.e.port, bin); err != nil {
+				if _, err := m.writeCIM(m.e.port, bin); err != nil {
 					fyne.Do(func() {
 						dialog.ShowError(err, m)
 						m.appTabs.SelectIndex(1)
@@ -374,7 +396,9 @@ func (m *mainWindow) eraseClickHandler() {
 				defer client.Close()
 
 				m.output("Erasing ... ")
-				if err := client.EraseCIM(); err != nil {
+				err := client.EraseCIM()
+				m.record(history.Erase, client, nil, err)
+				if err != nil {
 					m.output(err.Error())
 					return
 				}
@@ -462,5 +486,6 @@ func (m *mainWindow) enableButtons() {
 		m.readButton.Enable()
 		m.writeButton.Enable()
 		m.eraseButton.Enable()
+		m.e.showFirmwareNotice()
 	})
 }
@@ -0,0 +1,160 @@
+package gui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/eep/eeprom"
+)
+
+// mirrorView compares the banks the CIM stores twice and rebuilds a corrupt
+// copy from the valid one.
+type mirrorView struct {
+	vw    *viewerWindow
+	pairs []eeprom.MirrorPair
+
+	list          *widget.List
+	status        *widget.Label
+	rebuildButton *widget.Button
+}
+
+func newMirrorView(vw *viewerWindow) *mirrorView {
+	mv := &mirrorView{
+		vw:     vw,
+		status: widget.NewLabel(""),
+	}
+	mv.rebuildButton = widget.NewButtonWithIcon("Rebuild all", theme.ViewRefreshIcon(), func() {
+		var pairs []eeprom.MirrorPair
+		for _, p := range mv.pairs {
+			if p.Repairable() {
+				pairs = append(pairs, p)
+			}
+		}
+		mv.rebuild(pairs)
+	})
+	mv.list = &widget.List{
+		Length: func() int {
+			return len(mv.pairs)
+		},
+		CreateItem: func() fyne.CanvasObject {
+			return container.NewHBox(
+				widget.NewIcon(theme.ConfirmIcon()),
+				fixedWidth(160, &widget.Label{TextStyle: fyne.TextStyle{Bold: true}, Truncation: fyne.TextTruncateEllipsis}),
+				&widget.Label{TextStyle: fyne.TextStyle{Monospace: true}},
+				layout.NewSpacer(),
+				&widget.Label{TextStyle: fyne.TextStyle{Italic: true}},
+				widget.NewButtonWithIcon("Rebuild", theme.ViewRefreshIcon(), func() {}),
+			)
+		},
+		UpdateItem: func(item widget.ListItemID, obj fyne.CanvasObject) {
+			p := mv.pairs[item]
+			row := obj.(*fyne.Container)
+			var icon fyne.Resource
+			switch p.State {
+			case eeprom.MirrorOK:
+				icon = theme.ConfirmIcon()
+			case eeprom.MirrorBad1, eeprom.MirrorBad2:
+				icon = theme.WarningIcon()
+			default:
+				icon = theme.ErrorIcon()
+			}
+			row.Objects[0].(*widget.Icon).SetResource(icon)
+			row.Objects[1].(*fyne.Container).Objects[0].(*widget.Label).SetText(p.Name)
+			row.Objects[2].(*widget.Label).SetText(fmt.Sprintf("#1 0x%03X-0x%03X  #2 0x%03X-0x%03X",
+				p.Bank1.Checksum.Start, p.Bank1.End, p.Bank2.Checksum.Start, p.Bank2.End))
+			row.Objects[4].(*widget.Label).SetText(p.State.String())
+			button := row.Objects[5].(*widget.Button)
+			button.OnTapped = func() {
+				mv.rebuild([]eeprom.MirrorPair{p})
+			}
+			if p.Repairable() {
+				button.Enable()
+			} else {
+				button.Disable()
+			}
+		},
+	}
+	mv.reload()
+	return mv
+}
+
+func (mv *mirrorView) layout() fyne.CanvasObject {
+	return container.NewBorder(
+		nil,
+		container.NewBorder(nil, nil, nil, mv.rebuildButton, mv.status),
+		nil,
+		nil,
+		mv.list,
+	)
+}
+
+// reload compares the banks of the viewer's current image.
+func (mv *mirrorView) reload() {
+	mv.pairs = mv.vw.regionMap.Regions.Mirrors(xorImage(mv.vw.data))
+	var repairable, bad int
+	for _, p := range mv.pairs {
+		switch {
+		case p.Repairable():
+			repairable++
+		case p.State != eeprom.MirrorOK:
+			bad++
+		}
+	}
+	switch {
+	case len(mv.pairs) == 0:
+		mv.status.SetText("The layout has no mirrored banks")
+	case repairable == 0 && bad == 0:
+		mv.status.SetText(fmt.Sprintf("All %d mirrored banks are consistent", len(mv.pairs)))
+	default:
+		mv.status.SetText(fmt.Sprintf("%d pair(s) can be rebuilt, %d need manual attention", repairable, bad))
+	}
+	if repairable > 0 {
+		mv.rebuildButton.Enable()
+	} else {
+		mv.rebuildButton.Disable()
+	}
+	mv.list.Refresh()
+}
+
+// rebuild previews the image with the corrupt banks of pairs rebuilt and
+// applies it when confirmed.
+func (mv *mirrorView) rebuild(pairs []eeprom.MirrorPair) {
+	before := xorImage(mv.vw.data)
+	after, err := eeprom.RebuildMirrors(before, pairs)
+	if err != nil {
+		dialog.ShowError(err, mv.vw)
+		return
+	}
+	diff := eeprom.Compare("current", before, "rebuilt", after, mv.vw.regionMap.Regions)
+	if diff.Equal() {
+		dialog.ShowInformation("Rebuild banks", "Nothing to change", mv.vw)
+		return
+	}
+
+	preview := container.NewVScroll(&widget.Label{Text: diff.Text(), TextStyle: fyne.TextStyle{Monospace: true}})
+	preview.SetMinSize(fyne.NewSize(520, 360))
+	d := dialog.NewCustomConfirm("Rebuild banks", "Apply", "Cancel", preview, func(ok bool) {
+		if !ok {
+			return
+		}
+		for _, p := range pairs {
+			from := "#2"
+			if p.State == eeprom.MirrorBad2 {
+				from = "#1"
+			}
+			mv.vw.output("Rebuilt %s from bank %s", p.Name, from)
+		}
+		data := xorImage(after)
+		mv.vw.hexEditor.Load(data)
+		mv.vw.hexChanged(data)
+		if mv.vw.unparsed {
+			dialog.ShowInformation("Rebuild banks", "The banks were rebuilt, but the image still doesn't load as a CIM.\nSaving keeps the rebuilt image as a raw bin file.", mv.vw)
+		}
+	}, mv.vw)
+	d.Show()
+}
@@ -0,0 +1,194 @@
+package gui
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
+	"github.com/roffe/eep/report"
+	sdialog "github.com/sqweek/dialog"
+)
+
+// Report settings. The logo is stored as a path and read when a report is
+// made.
+const (
+	prefReportShop = "report_shop_name"
+	prefReportLogo = "report_logo"
+)
+
+// newReportWidgets builds the shop name and logo settings.
+func (sw *settingsWindow) newReportWidgets() fyne.CanvasObject {
+	prefs := sw.e.Preferences()
+	shop := widget.NewEntry()
+	shop.SetPlaceHolder("Shown on job reports")
+	shop.SetText(prefs.String(prefReportShop))
+	shop.OnChanged = func(s string) {
+		prefs.SetString(prefReportShop, s)
+	}
+
+	logo := &widget.Label{Truncation: fyne.TextTruncateEllipsis}
+	showLogo := func() {
+		if p := prefs.String(prefReportLogo); p != "" {
+			logo.SetText(filepath.Base(p))
+		} else {
+			logo.SetText("No logo")
+		}
+	}
+	showLogo()
+	choose := widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+		go func() {
+			filename, err := sdialog.File().Filter("Image", "png", "jpg", "jpeg", "gif").Title("Select report logo").Load()
+			if err != nil {
+				if err.Error() != "Cancelled" {
+					sw.e.mw.output("%s", err)
+				}
+				return
+			}
+			prefs.SetString(prefReportLogo, filename)
+			fyne.Do(showLogo)
+		}()
+	})
+	clearLogo := widget.NewButtonWithIcon("", theme.ContentClearIcon(), func() {
+		prefs.SetString(prefReportLogo, "")
+		showLogo()
+	})
+
+	return container.NewVBox(
+		container.NewBorder(nil, nil, widget.NewLabel("Shop name"), nil, shop),
+		container.NewBorder(nil, nil, widget.NewLabel("Report logo"), container.NewHBox(choose, clearLogo), logo),
+	)
+}
+
+// exportReport asks for a format and saves a job report for the viewer.
+func (vw *viewerWindow) exportReport() {
+	if err := vw.editable(); err != nil {
+		dialog.ShowError(err, vw)
+		return
+	}
+	r, err := vw.report()
+	if err != nil {
+		dialog.ShowError(err, vw)
+		return
+	}
+	d := dialog.NewCustomWithoutButtons("Job report", widget.NewLabel("Save the job report as:"), vw)
+	save := func(kind, ext string, render func() ([]byte, error)) func() {
+		return func() {
+			d.Hide()
+			b, err := render()
+			if err != nil {
+				dialog.ShowError(err, vw)
+				return
+			}
+			go func() {
+				name := fmt.Sprintf("report_%x_%s.%s", vw.cimBin.SnSticker, time.Now().Format("20060102-150405"), ext)
+				filename, err := sdialog.File().Filter(kind, ext).SetStartFile(name).Title("Save job report").Save()
+				if err != nil {
+					if err.Error() != "Cancelled" {
+						vw.e.mw.output("%s", err)
+					}
+					return
+				}
+				filename = addSuffix(filename, "."+ext)
+				if err := os.WriteFile(filename, b, 0644); err != nil {
+					fyne.Do(func() { dialog.ShowError(err, vw) })
+					return
+				}
+				vw.output("Saved job report to %s", filename)
+			}()
+		}
+	}
+	d.SetButtons([]fyne.CanvasObject{
+		widget.NewButton("Cancel", d.Hide),
+		widget.NewButtonWithIcon("HTML", theme.FileIcon(), save("HTML", "html", r.HTML)),
+		&widget.Button{Text: "PDF", Icon: theme.DocumentPrintIcon(), Importance: widget.HighImportance, OnTapped: save("PDF", "pdf", r.PDF)},
+	})
+	d.Show()
+}
+
+// report collects the job report for the viewer's current image.
+func (vw *viewerWindow) report() (*report.Report, error) {
+	prefs := vw.e.Preferences()
+	r := &report.Report{
+		Title:   "CIM job report",
+		Shop:    prefs.String(prefReportShop),
+		Created: time.Now(),
+	}
+	if p := prefs.String(prefReportLogo); p != "" {
+		logo, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("report logo: %w", err)
+		}
+		r.Logo = logo
+	}
+
+	bin := vw.cimBin
+	pin := "set"
+	if bytes.Equal(bin.Pin.Data1, []byte{0xFF, 0xFF, 0xFF, 0xFF}) {
+		pin = "not set"
+	}
+	sas := "No"
+	if bin.GetSasOpt() {
+		sas = "Yes"
+	}
+	r.Sections = append(r.Sections, report.Section{Title: "CIM", Fields: []report.Field{
+		{Label: "Source", Value: vw.filename},
+		{Label: "VIN", Value: bin.Vin.Data},
+		{Label: "Model year", Value: bin.ModelYear()},
+		{Label: "S/N sticker", Value: fmt.Sprintf("%X", bin.SnSticker)},
+		{Label: "End model (HW+SW)", Value: fmt.Sprintf("%d%s", bin.PartNo1, bin.PartNo1Rev)},
+		{Label: "Base model (HW+boot)", Value: fmt.Sprintf("%d%s", bin.PnBase1, bin.PnBase1Rev)},
+		{Label: "Delphi part number", Value: strconv.Itoa(int(bin.DelphiPN))},
+		{Label: "SAAB part number", Value: strconv.Itoa(int(bin.PartNo))},
+		{Label: "PIN", Value: pin},
+		{Label: "SAS option", Value: sas},
+	}})
+
+	keys := report.Section{Title: "Keys", Columns: []string{"#", "IDE", "Type", "Sync data"}, Empty: "No keys"}
+	for i := range vw.keys.count() {
+		sync := ""
+		if i < len(bin.Sync.Data) {
+			sync = fmt.Sprintf("%X", bin.Sync.Data[i])
+		}
+		keys.Rows = append(keys.Rows, []string{strconv.Itoa(i), fmt.Sprintf("%X", bin.Keys.Data1[i].Value), bin.Keys.Data1[i].Type(), sync})
+	}
+	r.Sections = append(r.Sections, keys)
+
+	images := report.Section{Title: "Images", Columns: []string{"Image", "MD5", "CRC32"}}
+	images.Rows = append(images.Rows, imageRow("Before", vw.original), imageRow("After", vw.data))
+	if bytes.Equal(vw.original, vw.data) {
+		images.Rows[1][0] = "After (unchanged)"
+	}
+	r.Sections = append(r.Sections, images)
+
+	ops := report.Section{Title: "Operations", Columns: []string{"Time", "Operation"}, Empty: "Nothing done to the image since it was opened"}
+	for _, op := range vw.operations() {
+		ops.Rows = append(ops.Rows, []string{op.time.Format("2006-01-02 15:04:05"), op.text})
+	}
+	r.Sections = append(r.Sections, ops)
+	return r, nil
+}
+
+func imageRow(label string, data []byte) []string {
+	sum, crc := imageHashes(data)
+	return []string{label, sum, crc}
+}
+
+// imageHashes hashes an image the way the Info tab does, falling back to
+// hashing the bytes when it doesn't load.
+func imageHashes(data []byte) (string, string) {
+	if bin, err := cim.MustLoadBytes("hash.bin", bytes.Clone(data)); err == nil {
+		return bin.MD5(), bin.CRC32()
+	}
+	return fmt.Sprintf("%x", md5.Sum(data)), fmt.Sprintf("%08x", crc32.ChecksumIEEE(data))
+}
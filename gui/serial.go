@@ -1,12 +1,16 @@
 package gui
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"github.com/roffe/cim/pkg/cim"
 	"github.com/roffe/eep/adapter"
+	"github.com/roffe/eep/eeprom"
+	"github.com/roffe/eep/history"
 )
 
 func (m *mainWindow) newAdapter() *adapter.Client {
@@ -27,53 +31,85 @@ func (m *mainWindow) newAdapter() *adapter.Client {
 	if err != nil {
 		panic(err)
 	}
-	return adapter.New(uint8(rd), uint8(wd)).OnMessage(onMessage).OnProgress(onProgress).OnError(onError)
+	return adapter.New(uint8(rd), uint8(wd)).OnMessage(onMessage).OnProgress(onProgress).OnError(onError).OnOutdated(m.e.adapterOutdated)
 
 }
 
-func (m *mainWindow) writeCIM(port string, data []byte) error {
+// writeCIM writes data to the CIM and returns the bytes sent to it.
+func (m *mainWindow) writeCIM(port string, data []byte) ([]byte, error) {
 	input, err := cim.MustLoadBytes("read.bin", data)
 	if err != nil {
-		return fmt.Errorf("Failed to load CIM: %w", err) //lint:ignore ST1005 ignore
+		return nil, fmt.Errorf("Failed to load CIM: %w", err) //lint:ignore ST1005 ignore
 	}
 
 	xorBytes, err := input.XORBytes()
 	if err != nil {
-		return fmt.Errorf("Failed to XOR CIM: %w", err) //lint:ignore ST1005 ignore
+		return nil, fmt.Errorf("Failed to XOR CIM: %w", err) //lint:ignore ST1005 ignore
 	}
 
 	client := m.newAdapter()
 	if err := client.Open(m.e.port, VERSION); err != nil {
-		return fmt.Errorf("Failed to init adapter: %w", err) //lint:ignore ST1005 ignore
+		return nil, fmt.Errorf("Failed to init adapter: %w", err) //lint:ignore ST1005 ignore
 	}
 	defer client.Close()
 
 	fyne.Do(func() { m.progressBar.Max = float64(len(xorBytes)) })
 
-	if err := client.WriteCIM(xorBytes); err != nil {
-		return fmt.Errorf("Failed to write CIM: %w", err) //lint:ignore ST1005 ignore
+	err = client.WriteCIM(xorBytes)
+	m.record(history.Write, client, xorBytes, err)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to write CIM: %w", err) //lint:ignore ST1005 ignore
+	}
+	return xorBytes, nil
+}
+
+// verifyCIM reads the CIM back and compares it with data, as returned by
+// writeCIM. The read back is part of the write and not recorded on its own.
+// Callers ask for it explicitly, so it runs regardless of the verify_write
+// preference.
+func (m *mainWindow) verifyCIM(data []byte) error {
+	rawBytes, _, err := m.readImage(false)
+	if rawBytes == nil {
+		return fmt.Errorf("Failed to verify CIM: %w", err) //lint:ignore ST1005 ignore
+	}
+	if !bytes.Equal(rawBytes, data) {
+		var regions []string
+		for _, rc := range eeprom.Compare("written", xorImage(data), "read", xorImage(rawBytes), layoutFor(data).Regions).Regions {
+			regions = append(regions, rc.String())
+		}
+		return fmt.Errorf("Verify failed: %s", strings.Join(regions, ", ")) //lint:ignore ST1005 ignore
 	}
 	return nil
 }
 
+// readCIM reads the CIM and records the read in the job history.
 func (m *mainWindow) readCIM() ([]byte, *cim.Bin, error) {
+	return m.readImage(true)
+}
+
+// readImage reads the CIM, recording the read in the job history if record
+// is set.
+func (m *mainWindow) readImage(record bool) (rawBytes []byte, bin *cim.Bin, err error) {
 	client := m.newAdapter()
 	if err := client.Open(m.e.port, VERSION); err != nil {
 		return nil, nil, fmt.Errorf("Failed to init adapter: %v", err) //lint:ignore ST1005 ignore
 	}
 	defer client.Close()
+	if record {
+		defer func() { m.record(history.Read, client, rawBytes, err) }()
+	}
 
 	fyne.Do(func() { m.progressBar.Max = 512 })
 
 	start := time.Now()
 	m.output("Reading CIM ...")
 
-	rawBytes, err := client.ReadCIM()
+	rawBytes, err = client.ReadCIM()
 	if err != nil {
 		return rawBytes, nil, fmt.Errorf("Failed to read CIM: %w", err) //lint:ignore ST1005 ignore
 	}
 	defer m.output("Read took %s", time.Since(start).String())
-	bin, err := cim.LoadBytes("read.bin", rawBytes)
+	bin, err = cim.LoadBytes("read.bin", rawBytes)
 	if err != nil {
 		return rawBytes, nil, fmt.Errorf("Failed to load CIM: %w", err) //lint:ignore ST1005 ignore
 	}
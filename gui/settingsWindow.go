@@ -1,9 +1,12 @@
 package gui
 
 import (
-	"bufio"
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -12,6 +15,8 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/roffe/eep/avr"
+	"github.com/roffe/eep/update"
+	sdialog "github.com/sqweek/dialog"
 )
 
 type settingsWindow struct {
@@ -23,6 +28,19 @@ type settingsWindow struct {
 	writeSliderLabel *widget.Label
 	writeSlider      *widget.Slider
 	updateButton     *widget.Button
+	backupButton     *widget.Button
+	restoreButton    *widget.Button
+	cancelButton     *widget.Button
+	firmwareStage    *widget.Label
+	firmwareProgress *widget.ProgressBar
+	diagButton       *widget.Button
+	diagLabel        *widget.Label
+	diagEEPROMButton *widget.Button
+	updateEndpoint   *widget.Entry
+	updateChannel    *widget.RadioGroup
+	checkUpdate      *widget.Button
+
+	cancel context.CancelFunc
 
 	fyne.Window
 }
@@ -30,10 +48,12 @@ type settingsWindow struct {
 func newSettingsWindow(e *EEPGui) *settingsWindow {
 	w := e.NewWindow("Settings")
 	w.CenterOnScreen()
+	var sw *settingsWindow
 	w.SetOnClosed(func() {
 		e.sw = nil
+		e.firmwareViews = slices.DeleteFunc(e.firmwareViews, func(v *settingsWindow) bool { return v == sw })
 	})
-	sw := &settingsWindow{
+	sw = &settingsWindow{
 		e:      e,
 		Window: w,
 		hwVerSelect: widget.NewSelect([]string{"Uno", "Nano", "Nano (old bootloader)"}, func(s string) {
@@ -78,33 +98,8 @@ func newSettingsWindow(e *EEPGui) *settingsWindow {
 		sw.e.writeDelayValue.Set(f)
 	}
 
-	sw.updateButton = widget.NewButtonWithIcon("Update firmware", theme.WarningIcon(), func() {
-		sw.updateButton.Disable()
-		defer sw.updateButton.Enable()
-
-		sw.e.mw.disableButtons()
-		defer sw.e.mw.enableButtons()
-
-		hwVer, err := sw.e.hwVersion.Get()
-		if err != nil {
-			hwVer = "Uno"
-		}
-
-		dialog.ShowInformation("Update", fmt.Sprintf("Updating firmware for %s", hwVer), sw.e.mw)
-
-		out, err := avr.Update(sw.e.port, hwVer, sw.e.mw.output)
-		if err != nil {
-			sw.e.mw.output("Error updating: %v", err)
-			return
-		}
-
-		r := bytes.NewReader(out)
-		scanner := bufio.NewScanner(r)
-		for scanner.Scan() {
-			sw.e.mw.output("%s", scanner.Text())
-		}
-
-	})
+	sw.newFirmwareButtons()
+	sw.newUpdateWidgets()
 
 	sw.SetContent(sw.layout())
 	w.Resize(fyne.NewSize(400, 220))
@@ -124,7 +119,17 @@ func (sw *settingsWindow) layout() fyne.CanvasObject {
 		sw.readSlider,
 		sw.writeSliderLabel,
 		sw.writeSlider,
+		container.NewBorder(nil, nil, widget.NewLabel("Update server"), sw.checkUpdate, sw.updateEndpoint),
+		container.NewHBox(widget.NewLabel("Release channel"), sw.updateChannel),
+		widget.NewAccordion(widget.NewAccordionItem("Job reports", sw.newReportWidgets())),
 		layout.NewSpacer(),
+		widget.NewAccordion(widget.NewAccordionItem("Diagnostics", container.NewVBox(
+			sw.diagLabel,
+			container.NewGridWithColumns(2, sw.diagButton, sw.diagEEPROMButton),
+		))),
+		sw.firmwareStage,
+		container.NewBorder(nil, nil, nil, sw.cancelButton, sw.firmwareProgress),
+		container.NewGridWithColumns(2, sw.backupButton, sw.restoreButton),
 		sw.updateButton,
 		//&widget.Button{
 		//	Icon: theme.DocumentSaveIcon(),
@@ -185,36 +190,250 @@ func newSettingsView(e *EEPGui) fyne.CanvasObject {
 		sw.e.writeDelayValue.Set(f)
 	}
 
-	sw.updateButton = widget.NewButtonWithIcon("Update firmware", theme.WarningIcon(), func() {
-		sw.updateButton.Disable()
-		sw.e.mw.disableButtons()
-		go func() {
-			fyne.Do(func() { sw.e.mw.appTabs.SelectIndex(1) })
-			defer fyne.Do(sw.updateButton.Enable)
-			defer sw.e.mw.enableButtons()
+	sw.newFirmwareButtons()
+	sw.newUpdateWidgets()
+
+	return sw.layout()
+}
+
+func (sw *settingsWindow) newUpdateWidgets() {
+	sw.updateEndpoint = widget.NewEntryWithData(sw.e.updateEndpoint)
+	sw.updateEndpoint.OnChanged = func(s string) {
+		sw.e.Preferences().SetString("update_endpoint", s)
+		sw.e.updateEndpoint.Set(s)
+	}
+	sw.updateChannel = widget.NewRadioGroup([]string{"Stable", "Beta"}, func(s string) {
+		ch := update.Stable
+		if s == "Beta" {
+			ch = update.Beta
+		}
+		sw.e.Preferences().SetString("update_channel", string(ch))
+		sw.e.updateChannel.Set(string(ch))
+	})
+	sw.updateChannel.Horizontal = true
+	sw.updateChannel.Required = true
+	if sw.e.channel() == update.Beta {
+		sw.updateChannel.SetSelected("Beta")
+	} else {
+		sw.updateChannel.SetSelected("Stable")
+	}
+	sw.checkUpdate = widget.NewButtonWithIcon("Check now", theme.ViewRefreshIcon(), sw.e.CheckUpdate)
+}
+
+func (sw *settingsWindow) newFirmwareButtons() {
+	sw.firmwareProgress = widget.NewProgressBar()
+	sw.firmwareStage = widget.NewLabel("")
+	sw.cancelButton = widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), func() {
+		if sw.cancel != nil {
+			sw.cancel()
+		}
+	})
+	sw.cancelButton.Disable()
+	sw.e.firmwareViews = append(sw.e.firmwareViews, sw)
 
-			hwVer, err := sw.e.hwVersion.Get()
+	sw.diagLabel = &widget.Label{
+		Text:      "Read diagnostics to check the adapter's fuses and EEPROM",
+		TextStyle: fyne.TextStyle{Monospace: true},
+	}
+	var eepromDump string
+	sw.diagEEPROMButton = widget.NewButtonWithIcon("Show EEPROM", theme.SearchIcon(), func() {
+		dump := widget.NewLabelWithStyle(eepromDump, fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+		d := dialog.NewCustom("Adapter EEPROM", "Close", container.NewVScroll(dump), sw.e.mw)
+		d.Resize(fyne.NewSize(640, 480))
+		d.Show()
+	})
+	sw.diagEEPROMButton.Disable()
+	sw.diagButton = widget.NewButtonWithIcon("Read diagnostics", theme.InfoIcon(), func() {
+		sw.runFirmwareJob(func(ctx context.Context, hwVer string) error {
+			diag, err := avr.ReadDiagnostics(ctx, sw.e.port, hwVer, sw.onFirmwareEvent)
 			if err != nil {
-				hwVer = "Uno"
+				return err
 			}
+			sw.e.mw.output("%s", diag.String())
+			fyne.Do(func() {
+				eepromDump = diag.EEPROMDump()
+				sw.diagLabel.SetText(diag.String())
+				sw.diagEEPROMButton.Enable()
+			})
+			return nil
+		})
+	})
 
-			out, err := avr.Update(sw.e.port, hwVer, sw.e.mw.output)
+	sw.updateButton = widget.NewButtonWithIcon("Update firmware", theme.WarningIcon(), sw.confirmUpdateFirmware)
+	sw.backupButton = widget.NewButtonWithIcon("Backup firmware", theme.DownloadIcon(), func() {
+		sw.runFirmwareJob(sw.backupFirmware)
+	})
+	sw.restoreButton = widget.NewButtonWithIcon("Restore firmware", theme.UploadIcon(), func() {
+		sw.runFirmwareJob(func(ctx context.Context, hwVer string) error {
+			filename, err := sdialog.File().Filter("Intel HEX", "hex").Title("Select firmware backup to restore").Load()
 			if err != nil {
-				sw.e.mw.output("Error updating: %v", err)
-				return
+				return err
 			}
-
-			r := bytes.NewReader(out)
-			scanner := bufio.NewScanner(r)
-			for scanner.Scan() {
-				sw.e.mw.output("%s", scanner.Text())
+			hexData, err := os.ReadFile(filename)
+			if err != nil {
+				return err
+			}
+			report, err := avr.Restore(ctx, sw.e.port, hwVer, hexData, sw.onFirmwareEvent)
+			if report != nil {
+				sw.e.mw.output("%s", report.String())
+			}
+			if err != nil {
+				return err
 			}
 			fyne.Do(func() {
-				sw.e.mw.appTabs.SelectIndex(len(sw.e.mw.appTabs.Items) - 1)
-				dialog.ShowInformation("Update", "Firmware update complete", sw.e.mw)
+				dialog.ShowInformation("Restore", "Firmware restore complete\n\n"+report.String(), sw.e.mw)
 			})
-		}()
+			return nil
+		})
 	})
+	if sw.e.firmwareBusy {
+		sw.disableFirmwareButtons(false)
+	}
+}
 
-	return sw.layout()
+// confirmUpdateFirmware offers to back up the adapter firmware before
+// updating it, or to not update at all.
+func (sw *settingsWindow) confirmUpdateFirmware() {
+	d := dialog.NewCustomWithoutButtons("Update firmware", widget.NewLabel("Backup the current adapter firmware before updating?"), sw.e.mw)
+	update := func(backup bool) func() {
+		return func() {
+			d.Hide()
+			sw.updateFirmware(backup)
+		}
+	}
+	d.SetButtons([]fyne.CanvasObject{
+		widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), d.Hide),
+		widget.NewButton("Skip backup", update(false)),
+		&widget.Button{Text: "Backup", Icon: theme.DownloadIcon(), Importance: widget.HighImportance, OnTapped: update(true)},
+	})
+	d.Show()
+}
+
+func (sw *settingsWindow) updateFirmware(backup bool) {
+	sw.runFirmwareJob(func(ctx context.Context, hwVer string) error {
+		if backup {
+			if err := sw.backupFirmware(ctx, hwVer); err != nil {
+				return fmt.Errorf("backup failed, not updating: %w", err)
+			}
+		}
+
+		report, err := avr.Update(ctx, sw.e.port, hwVer, sw.onFirmwareEvent)
+		if report != nil {
+			sw.e.mw.output("%s", report.String())
+		}
+		if err != nil {
+			return err
+		}
+
+		msg := "Firmware update complete\n\n" + report.String()
+		if report.NewVersion != VERSION {
+			msg += fmt.Sprintf("\n\nWarning: adapter answers %s, expected %s", report.NewVersion, VERSION)
+		}
+		fyne.Do(func() {
+			dialog.ShowInformation("Update", msg, sw.e.mw)
+		})
+		return nil
+	})
+}
+
+// runFirmwareJob runs f in the background with the adapter buttons disabled
+// until it returns or is cancelled.
+func (sw *settingsWindow) runFirmwareJob(f func(ctx context.Context, hwVer string) error) {
+	if sw.e.port == "" {
+		sw.e.mw.output("Please select a port first")
+		return
+	}
+	if sw.e.firmwareBusy {
+		sw.e.mw.output("A firmware job is already running")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sw.cancel = cancel
+	sw.e.setFirmwareBusy(sw, true)
+	sw.e.mw.disableButtons()
+	go func() {
+		defer fyne.Do(func() { sw.e.setFirmwareBusy(sw, false) })
+		defer sw.e.mw.enableButtons()
+		defer cancel()
+
+		hwVer, err := sw.e.hwVersion.Get()
+		if err != nil {
+			hwVer = "Uno"
+		}
+
+		if err := f(ctx, hwVer); err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, sdialog.ErrCancelled) {
+				sw.e.mw.output("Firmware job cancelled")
+				fyne.Do(func() { sw.firmwareStage.SetText("Cancelled") })
+				return
+			}
+			sw.e.mw.output("Error: %v", err)
+			fyne.Do(func() { sw.firmwareStage.SetText("Failed") })
+		}
+	}()
+}
+
+func (sw *settingsWindow) onFirmwareEvent(ev avr.Event) {
+	if ev.Message != "" {
+		sw.e.mw.output("%s", ev.Message)
+	}
+	fyne.Do(func() {
+		if ev.Pages > 0 {
+			sw.firmwareStage.SetText(fmt.Sprintf("%s page %d/%d (%d bytes)", ev.Stage, ev.Page, ev.Pages, ev.Bytes))
+			sw.firmwareProgress.Max = float64(ev.Pages)
+			sw.firmwareProgress.SetValue(float64(ev.Page))
+			return
+		}
+		sw.firmwareStage.SetText(ev.Stage.String())
+	})
+}
+
+// backupFirmware reads the adapter flash and asks where to save it.
+func (sw *settingsWindow) backupFirmware(ctx context.Context, hwVer string) error {
+	hexData, err := avr.Backup(ctx, sw.e.port, hwVer, sw.onFirmwareEvent)
+	if err != nil {
+		return err
+	}
+	filename, err := sdialog.File().Filter("Intel HEX", "hex").SetStartFile(fmt.Sprintf("adapter_backup_%s.hex", time.Now().Format("20060102-150405"))).Title("Save firmware backup").Save()
+	if err != nil {
+		return err
+	}
+	filename = addSuffix(filename, ".hex")
+	if err := os.WriteFile(filename, hexData, 0644); err != nil {
+		return err
+	}
+	sw.e.mw.output("Saved firmware backup to %s", filename)
+	return nil
+}
+
+// setFirmwareBusy disables the firmware buttons of every settings view
+// while a firmware job runs. Only owner, the view that started the job, can
+// cancel it.
+func (e *EEPGui) setFirmwareBusy(owner *settingsWindow, busy bool) {
+	e.firmwareBusy = busy
+	for _, sw := range e.firmwareViews {
+		if busy {
+			sw.disableFirmwareButtons(sw == owner)
+		} else {
+			sw.enableFirmwareButtons()
+		}
+	}
+}
+
+func (sw *settingsWindow) disableFirmwareButtons(cancellable bool) {
+	sw.updateButton.Disable()
+	sw.backupButton.Disable()
+	sw.restoreButton.Disable()
+	sw.diagButton.Disable()
+	if cancellable {
+		sw.cancelButton.Enable()
+	}
+}
+
+func (sw *settingsWindow) enableFirmwareButtons() {
+	sw.updateButton.Enable()
+	sw.backupButton.Enable()
+	sw.restoreButton.Enable()
+	sw.diagButton.Enable()
+	sw.cancelButton.Disable()
 }
@@ -0,0 +1,253 @@
+package gui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
+	"github.com/roffe/eep/eeprom"
+)
+
+// transplantGroups are the identity fields carried from a dead CIM to its
+// replacement. SAS calibration and part numbers stay the replacement's own.
+var transplantGroups = []eeprom.Group{
+	{Name: "VIN", Regions: []string{"VIN Data", "VIN Value", "VIN Unknown", "VIN SPS Count"}},
+	{Name: "ISK", Regions: []string{"KEYS ISK High #1", "KEYS ISK Low #1", "KEYS ISK High #2", "KEYS ISK Low #2"}},
+	{Name: "PSK", Regions: []string{"PSK High", "PSK Low"}},
+	{Name: "PIN", Regions: []string{"PIN Data #1", "PIN Data #2"}},
+	{Name: "Keys", Regions: []string{"KEYS Data #1", "KEYS Count #1", "KEYS Data #2", "KEYS Count #2"}},
+	{Name: "Sync data", Regions: []string{"Sync Data", "Sync Bank #1", "Sync Bank #2"}},
+	{Name: "SAS option", Regions: []string{"SAS Option"}},
+}
+
+// transplantWizard walks through copying the identity of one CIM onto
+// another: pick the dumps, pick the field groups, review, then save or write.
+type transplantWizard struct {
+	e *EEPGui
+
+	source, target *compareSource
+	groups         []*widget.Check
+
+	result []byte // file form
+	diff   *eeprom.Diff
+	fixed  []eeprom.Region
+
+	step    int
+	steps   []func() fyne.CanvasObject
+	body    *fyne.Container
+	title   *widget.Label
+	back    *widget.Button
+	next    *widget.Button
+	dialog  *dialog.CustomDialog
+	sourceL *widget.Label
+	targetL *widget.Label
+}
+
+func (e *EEPGui) transplantWizard() {
+	w := &transplantWizard{
+		e:       e,
+		body:    container.NewStack(),
+		title:   &widget.Label{TextStyle: fyne.TextStyle{Bold: true}},
+		sourceL: widget.NewLabel("not selected"),
+		targetL: widget.NewLabel("not selected"),
+	}
+	for _, g := range transplantGroups {
+		c := widget.NewCheck(fmt.Sprintf("%s (%s)", g.Name, strings.Join(g.Regions, ", ")), nil)
+		c.SetChecked(true)
+		w.groups = append(w.groups, c)
+	}
+	w.steps = []func() fyne.CanvasObject{w.dumpsStep, w.groupsStep, w.previewStep, w.finishStep}
+
+	w.back = widget.NewButtonWithIcon("Back", theme.NavigateBackIcon(), func() { w.show(w.step - 1) })
+	w.next = &widget.Button{Text: "Next", Icon: theme.NavigateNextIcon(), IconPlacement: widget.ButtonIconTrailingText, Importance: widget.HighImportance, OnTapped: w.advance}
+
+	w.dialog = dialog.NewCustomWithoutButtons("CIM transplant", container.NewBorder(w.title, nil, nil, nil, w.body), e.mw)
+	w.dialog.SetButtons([]fyne.CanvasObject{widget.NewButton("Close", w.dialog.Hide), w.back, w.next})
+	w.dialog.Resize(fyne.NewSize(720, 480))
+	w.show(0)
+	w.dialog.Show()
+}
+
+func (w *transplantWizard) show(step int) {
+	w.step = step
+	w.title.SetText(fmt.Sprintf("Step %d of %d", step+1, len(w.steps)))
+	w.body.Objects = []fyne.CanvasObject{w.steps[step]()}
+	w.body.Refresh()
+	if step == 0 {
+		w.back.Disable()
+	} else {
+		w.back.Enable()
+	}
+	if step == len(w.steps)-1 {
+		w.next.Hide()
+	} else {
+		w.next.Show()
+	}
+	w.updateNext()
+}
+
+func (w *transplantWizard) updateNext() {
+	if w.step == 0 && (w.source == nil || w.target == nil) {
+		w.next.Disable()
+		return
+	}
+	w.next.Enable()
+}
+
+// advance validates the current step before moving on.
+func (w *transplantWizard) advance() {
+	switch w.step {
+	case 0:
+		if len(w.source.data) != eeprom.Size || len(w.target.data) != eeprom.Size {
+			dialog.ShowError(fmt.Errorf("both dumps must be %d byte CIM images", eeprom.Size), w.e.mw)
+			return
+		}
+	case 1:
+		if err := w.build(); err != nil {
+			dialog.ShowError(err, w.e.mw)
+			return
+		}
+	}
+	w.show(w.step + 1)
+}
+
+func (w *transplantWizard) dumpsStep() fyne.CanvasObject {
+	pick := func(title string, label *widget.Label, set func(*compareSource)) *widget.Button {
+		return widget.NewButtonWithIcon("Select...", theme.FolderOpenIcon(), func() {
+			chooseDump(w.e, title, func(src *compareSource) {
+				set(src)
+				label.SetText(src.name)
+				w.updateNext()
+			})
+		})
+	}
+	return container.NewVBox(
+		widget.NewLabel("Copy the identity of a dead CIM onto a replacement unit."),
+		widget.NewForm(
+			widget.NewFormItem("Source (dead CIM)", container.NewBorder(nil, nil, nil,
+				pick("Select source dump", w.sourceL, func(s *compareSource) { w.source = s }), w.sourceL)),
+			widget.NewFormItem("Target (replacement)", container.NewBorder(nil, nil, nil,
+				pick("Select target dump", w.targetL, func(s *compareSource) { w.target = s }), w.targetL)),
+		),
+	)
+}
+
+func (w *transplantWizard) groupsStep() fyne.CanvasObject {
+	list := container.NewVBox(widget.NewLabel(fmt.Sprintf("Copy from %s to %s:", w.source.name, w.target.name)))
+	for _, c := range w.groups {
+		list.Add(c)
+	}
+	list.Add(widget.NewLabel("Everything else, like the SAS calibration and part numbers, is kept from the target."))
+	return container.NewVScroll(list)
+}
+
+// build produces the target image with the chosen groups copied in.
+func (w *transplantWizard) build() error {
+	var names []string
+	for i, c := range w.groups {
+		if c.Checked {
+			names = append(names, transplantGroups[i].Regions...)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("select at least one group to copy")
+	}
+	regions := w.target.regions
+	img := xorImage(w.target.data)
+	_, fixed, err := regions.Transplant(img, xorImage(w.source.data), names)
+	if err != nil {
+		return err
+	}
+	result := xorImage(img)
+	if _, err := cim.MustLoadBytes("transplant.bin", bytes.Clone(result)); err != nil {
+		return fmt.Errorf("the result is not a valid CIM: %w", err)
+	}
+	w.result = result
+	w.fixed = fixed
+	w.diff = eeprom.Compare(w.target.name, xorImage(w.target.data), "result", img, regions)
+	return nil
+}
+
+func (w *transplantWizard) previewStep() fyne.CanvasObject {
+	var sb strings.Builder
+	if w.diff.Equal() {
+		sb.WriteString("The target already holds these values, nothing changes.\n")
+	}
+	for _, rc := range w.diff.Regions {
+		sb.WriteString(rc.String() + "\n")
+		if rc.Name != "" {
+			fmt.Fprintf(&sb, "  %s\n  -> %s\n", rc.A, rc.B)
+		}
+	}
+	if len(w.fixed) > 0 {
+		var names []string
+		for _, r := range w.fixed {
+			names = append(names, r.Name)
+		}
+		fmt.Fprintf(&sb, "\nRecomputed checksums: %s\n", strings.Join(names, ", "))
+	}
+	return container.NewBorder(
+		widget.NewLabel(fmt.Sprintf("Changes to %s:", w.target.name)),
+		nil, nil, nil,
+		container.NewVScroll(&widget.Label{Text: sb.String(), TextStyle: fyne.TextStyle{Monospace: true}}),
+	)
+}
+
+func (w *transplantWizard) finishStep() fyne.CanvasObject {
+	m := w.e.mw
+	status := widget.NewLabel("")
+	open := widget.NewButtonWithIcon("Open in new tab", theme.FileIcon(), func() {
+		m.docTab.Append(container.NewTabItemWithIcon("Transplant "+w.target.name, theme.FileIcon(), newViewerView(w.e, "transplant of "+w.source.name, bytes.Clone(w.result), true)))
+		m.appTabs.SelectIndex(0)
+		m.docTab.SelectIndex(len(m.docTab.Items) - 1)
+		w.dialog.Hide()
+	})
+	save := widget.NewButtonWithIcon("Save...", theme.DocumentSaveIcon(), func() {
+		go m.saveFile("Save transplanted bin file", fmt.Sprintf("cim_transplant_%s.bin", time.Now().Format("20060102-150405")), w.result)
+	})
+	var write *widget.Button
+	write = widget.NewButtonWithIcon("Write to CIM and verify", theme.UploadIcon(), func() {
+		if w.e.port == "" {
+			dialog.ShowError(fmt.Errorf("select a port first"), m)
+			return
+		}
+		dialog.ShowConfirm("Write to CIM?", "Write the transplanted image to the CIM on "+w.e.port+"?", func(ok bool) {
+			if !ok {
+				return
+			}
+			write.Disable()
+			status.SetText("Writing ...")
+			go func() {
+				m.disableButtons()
+				defer m.enableButtons()
+				written, err := m.writeCIM(w.e.port, w.result)
+				if err == nil {
+					fyne.Do(func() { status.SetText("Verifying ...") })
+					err = m.verifyCIM(written)
+				}
+				fyne.Do(func() {
+					write.Enable()
+					if err != nil {
+						status.SetText("Failed: " + err.Error())
+						dialog.ShowError(err, m)
+						return
+					}
+					status.SetText("Written and verified")
+					m.output("Transplanted %s onto %s and verified the write", w.source.name, w.target.name)
+				})
+			}()
+		}, m)
+	})
+	return container.NewVBox(
+		widget.NewLabel("The target image is ready."),
+		container.NewHBox(open, save, write),
+		status,
+	)
+}
@@ -0,0 +1,61 @@
+package gui
+
+import (
+	"bytes"
+	"fmt"
+
+	"fyne.io/fyne/v2/dialog"
+	"github.com/roffe/cim/pkg/cim"
+)
+
+// undoStep is the image, in file form, from before a change.
+type undoStep struct {
+	desc string
+	data []byte
+}
+
+// commit records a change made to vw.cimBin. before is the image, in file
+// form, from before the change. The change goes on the undo stack and the
+// document is marked unsaved.
+func (vw *viewerWindow) commit(desc string, before []byte) error {
+	data, err := vw.cimBin.XORBytes()
+	if err != nil {
+		return err
+	}
+	vw.undo = append(vw.undo, undoStep{desc: desc, data: before})
+	vw.output("%s", desc)
+	vw.data = data
+	vw.askSaveOnClose = true
+	vw.saved = false
+	return nil
+}
+
+// restore puts back an image taken before a change.
+func (vw *viewerWindow) restore(data []byte) {
+	bin, err := cim.MustLoadBytes("undo.bin", bytes.Clone(data))
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to restore image: %w", err), vw)
+		return
+	}
+	vw.cimBin = bin
+	vw.data = data
+	vw.unparsed = false
+	vw.refreshTabs()
+}
+
+// undoChange reverts the last change made on the Info or Keys tab.
+func (vw *viewerWindow) undoChange() {
+	if len(vw.undo) == 0 {
+		return
+	}
+	if err := vw.editable(); err != nil {
+		dialog.ShowError(err, vw)
+		return
+	}
+	step := vw.undo[len(vw.undo)-1]
+	vw.undo = vw.undo[:len(vw.undo)-1]
+	vw.askSaveOnClose = true
+	vw.saved = false
+	vw.restore(step.data)
+	vw.output("Undid: %s", step.desc)
+}
@@ -0,0 +1,244 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/eep/update"
+	sdialog "github.com/sqweek/dialog"
+)
+
+func (e *EEPGui) updateClient() *update.Client {
+	endpoint, err := e.updateEndpoint.Get()
+	if err != nil || endpoint == "" {
+		endpoint = update.DefaultEndpoint
+	}
+	return update.New(endpoint)
+}
+
+func (e *EEPGui) channel() update.Channel {
+	ch, err := e.updateChannel.Get()
+	if err != nil {
+		return update.Stable
+	}
+	return update.ParseChannel(ch)
+}
+
+// newChangelog renders the release notes of releases in the given order.
+func newChangelog(releases []*update.Release) fyne.CanvasObject {
+	var content []fyne.CanvasObject
+	for _, rel := range releases {
+		content = append(content, widget.NewRichTextFromMarkdown("# "+rel.TagName+"  \n\n"+rel.Body))
+	}
+	return container.NewVScroll(container.NewVBox(content...))
+}
+
+// newChangelogView loads all releases in the background and shows their notes.
+func newChangelogView(e *EEPGui) fyne.CanvasObject {
+	status := widget.NewLabel("Loading releases ...")
+	view := container.NewStack(status)
+	go func() {
+		releases, err := e.updateClient().Releases(context.Background())
+		fyne.Do(func() {
+			if err != nil {
+				status.SetText(fmt.Sprintf("Could not load releases: %v", err))
+				return
+			}
+			view.Objects = []fyne.CanvasObject{newChangelog(releases)}
+			view.Refresh()
+		})
+	}()
+	return view
+}
+
+func (e *EEPGui) showUpdate(latest *update.Release) {
+	content := container.NewBorder(
+		widget.NewLabel(fmt.Sprintf("Version %s is available, you are running %s", latest.TagName, VERSION)),
+		nil,
+		nil,
+		nil,
+		newChangelog([]*update.Release{latest}),
+	)
+	d := dialog.NewCustomWithoutButtons("Software update", content, e.mw)
+	d.SetButtons([]fyne.CanvasObject{
+		widget.NewButton("Later", d.Hide),
+		widget.NewButtonWithIcon("Download", theme.DownloadIcon(), func() {
+			d.Hide()
+			e.downloadUpdate(latest)
+		}),
+		&widget.Button{Text: "Install", Icon: theme.ConfirmIcon(), Importance: widget.HighImportance, OnTapped: func() {
+			d.Hide()
+			e.installUpdate(latest)
+		}},
+	})
+	d.Resize(fyne.NewSize(640, 480))
+	d.Show()
+}
+
+// downloadUpdate saves the release asset for this OS and arch where the user
+// chooses.
+func (e *EEPGui) downloadUpdate(rel *update.Release) {
+	asset, err := rel.Asset(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		dialog.ShowConfirm("Software update", err.Error()+"\nOpen the download page instead?", e.openWebpage, e.mw)
+		return
+	}
+	go func() {
+		filename, err := sdialog.File().SetStartFile(asset.Name).Title("Save update").Save()
+		if err != nil {
+			if err.Error() != "Cancelled" {
+				e.mw.output("%s", err)
+			}
+			return
+		}
+		if err := e.fetchUpdate(rel, asset, filename); err != nil {
+			return
+		}
+		fyne.Do(func() {
+			dialog.ShowInformation("Software update", fmt.Sprintf("Downloaded and verified %s to\n%s", asset.Name, filename), e.mw)
+		})
+	}()
+}
+
+// installUpdate downloads and verifies the release, stages it next to the
+// running executable and offers to restart into it.
+func (e *EEPGui) installUpdate(rel *update.Release) {
+	asset, err := rel.Asset(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		dialog.ShowConfirm("Software update", err.Error()+"\nOpen the download page instead?", e.openWebpage, e.mw)
+		return
+	}
+	go func() {
+		exe, err := update.Executable()
+		if err != nil {
+			e.mw.output("Can't locate CIM Tool executable: %v", err)
+			return
+		}
+		dir, err := os.MkdirTemp("", "cimtool-update")
+		if err != nil {
+			e.mw.output("%s", err)
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		archive := filepath.Join(dir, asset.Name)
+		if err := e.fetchUpdate(rel, asset, archive); err != nil {
+			return
+		}
+		staged, err := update.Stage(exe, archive)
+		if err != nil {
+			e.mw.output("Failed to stage update: %v", err)
+			fyne.Do(func() { dialog.ShowError(err, e.mw) })
+			return
+		}
+		e.mw.output("Staged %s for install", rel.TagName)
+		fyne.Do(func() {
+			dialog.ShowConfirm("Update ready", fmt.Sprintf("Restart CIM Tool now to run %s?", rel.TagName), func(ok bool) {
+				e.applyUpdate(staged, rel, ok)
+			}, e.mw)
+		})
+	}()
+}
+
+// applyUpdate swaps the staged executable in. When restarting it waits for
+// the new version to come up and rolls back if it doesn't.
+func (e *EEPGui) applyUpdate(staged *update.Staged, rel *update.Release, restart bool) {
+	if err := staged.Swap(); err != nil {
+		e.mw.output("Failed to install update: %v", err)
+		dialog.ShowError(err, e.mw)
+		return
+	}
+	if !restart {
+		e.mw.output("%s installed, it will be used the next time CIM Tool starts", rel.TagName)
+		return
+	}
+
+	wait := dialog.NewCustomWithoutButtons("Software update", widget.NewLabel("Starting "+rel.TagName+" ..."), e.mw)
+	wait.Show()
+	go func() {
+		err := staged.Restart(30*time.Second, os.Args[1:]...)
+		fyne.Do(func() {
+			wait.Hide()
+			if err != nil {
+				e.mw.output("Update failed: %v", err)
+				dialog.ShowError(err, e.mw)
+				return
+			}
+			e.Quit()
+		})
+	}()
+}
+
+// fetchUpdate downloads and verifies asset to filename behind a progress
+// dialog. Errors are logged and shown before being returned.
+func (e *EEPGui) fetchUpdate(rel *update.Release, asset *update.Assets, filename string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	progress := widget.NewProgressBar()
+	var d *dialog.CustomDialog
+	fyne.DoAndWait(func() {
+		d = dialog.NewCustom("Downloading "+asset.Name, "Cancel", progress, e.mw)
+		d.SetOnClosed(cancel)
+		d.Resize(fyne.NewSize(400, 100))
+		d.Show()
+	})
+
+	err := downloadFile(ctx, e.updateClient(), rel, asset, filename, func(done, total int64) {
+		fyne.Do(func() {
+			if total > 0 {
+				progress.Max = float64(total)
+			}
+			progress.SetValue(float64(done))
+		})
+	})
+	fyne.Do(func() {
+		d.Hide()
+		if err != nil {
+			e.mw.output("Update download failed: %v", err)
+			dialog.ShowError(fmt.Errorf("download failed: %w", err), e.mw)
+			return
+		}
+		e.mw.output("Downloaded and verified %s", asset.Name)
+	})
+	return err
+}
+
+// downloadFile downloads asset next to filename and verifies it against the
+// release checksums before moving it in place. Nothing is left behind on
+// failure.
+func downloadFile(ctx context.Context, c *update.Client, rel *update.Release, asset *update.Assets, filename string, onProgress func(done, total int64)) error {
+	partial := filename + ".part"
+	f, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partial)
+
+	if err := c.Download(ctx, asset, f, onProgress); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	if err := c.Verify(ctx, rel, asset, f); err != nil {
+		f.Close()
+		return fmt.Errorf("verification failed, not installing: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partial, filename)
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -14,6 +16,7 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/roffe/cim/pkg/cim"
+	"github.com/roffe/eep/eeprom"
 )
 
 type viewerWindow struct {
@@ -22,52 +25,141 @@ type viewerWindow struct {
 	saved          bool
 	askSaveOnClose bool
 
-	data   []byte
-	cimBin *cim.Bin
-
-	keyList *widget.List
+	filename  string
+	original  []byte      // the image as opened, for the job report
+	ops       []operation // logged by this viewer, for the job report
+	opsMu     sync.Mutex
+	data      []byte
+	cimBin    *cim.Bin
+	regionMap *eeprom.Definition
+	// unparsed is set while vw.data holds hex edits that don't load as a
+	// CIM, vw.cimBin then still holds the image from before them
+	unparsed bool
+
+	keys *keysView
+	info *infoView
+	// undo holds the image before each Info and Keys tab change
+	undo []undoStep
 
 	toolbar    *widget.Toolbar
 	infoTab    *container.TabItem
 	versionTab *container.TabItem
+	vinTab     *container.TabItem
+	hexTab     *container.TabItem
+	editTab    *container.TabItem
+	checkTab   *container.TabItem
+	mirrorTab  *container.TabItem
 	tabs       *container.AppTabs
 
+	editor    *editView
+	hexEditor *hexEditor
+	checks    *checkView
+	mirrors   *mirrorView
+
 	fyne.Window
 }
 
 func newViewerView(e *EEPGui, filename string, data []byte, askSaveOnClose bool) fyne.CanvasObject {
 	vw := &viewerWindow{
-		e:      e,
-		data:   data,
-		Window: e.mw,
+		e:         e,
+		filename:  filename,
+		original:  bytes.Clone(data),
+		data:      data,
+		regionMap: layoutFor(data),
+		Window:    e.mw,
 	}
 
+	var content fyne.CanvasObject
 	if bin, err := cim.MustLoadBytes(filename, data); err == nil {
 		vw.cimBin = bin
+		content = vw.layout()
 	} else {
 		vw.toolbar = vw.newToolbar()
-		return container.NewBorder(vw.toolbar, nil, nil, nil,
-			newHexView(vw),
+		vw.hexTab = container.NewTabItemWithIcon("Hex", theme.SearchIcon(), newHexView(vw))
+		vw.checks = newCheckView(vw)
+		vw.checkTab = container.NewTabItemWithIcon("Checks", theme.ConfirmIcon(), vw.checks.layout())
+		vw.mirrors = newMirrorView(vw)
+		vw.mirrorTab = container.NewTabItemWithIcon("Mirrors", theme.ContentCopyIcon(), vw.mirrors.layout())
+		vw.tabs = container.NewAppTabs(vw.hexTab, vw.checkTab, vw.mirrorTab)
+		if vw.checks.failed() > 0 {
+			vw.tabs.Select(vw.checkTab)
+		}
+		content = container.NewBorder(vw.toolbar, nil, nil, nil,
+			vw.tabs,
 		)
 	}
-
-	return vw.layout()
+	e.mw.viewers[content] = vw
+	return content
 
 }
 
-func (vw *viewerWindow) save() {
-	if vw.cimBin != nil {
-		bin, err := vw.cimBin.XORBytes()
-		if err != nil {
-			dialog.ShowError(err, vw)
-			return
+// promote replaces a raw viewer whose hex edits made the image load as a
+// CIM with a full viewer of the edited image.
+func (vw *viewerWindow) promote() {
+	m := vw.e.mw
+	for _, item := range m.docTab.Items {
+		if m.viewers[item.Content] != vw {
+			continue
 		}
-		vw.e.mw.saveFile("Save bin file",
-			fmt.Sprintf("cim_%x_%s.bin", vw.cimBin.SnSticker, time.Now().Format("20060102-150405")),
-			bin)
+		content := newViewerView(vw.e, vw.filename, bytes.Clone(vw.data), true)
+		nvw := m.viewers[content]
+		nvw.original = vw.original
+		nvw.ops = vw.operations()
+		delete(m.viewers, item.Content)
+		item.Content = content
+		m.docTab.Refresh()
 		return
 	}
-	vw.e.mw.saveFile("Sav raw bin file", fmt.Sprintf("cim_raw_%s.bin", time.Now().Format("20060102-150405")), vw.data)
+}
+
+// save asks where to save the image and reports whether it was saved. Hex
+// edits that don't load as a CIM are saved as they are.
+func (vw *viewerWindow) save() bool {
+	if vw.cimBin == nil || vw.unparsed {
+		if !vw.e.mw.saveFile("Save raw bin file", fmt.Sprintf("cim_raw_%s.bin", time.Now().Format("20060102-150405")), vw.data) {
+			return false
+		}
+		vw.logOp("Saved the raw image")
+		return true
+	}
+	bin, err := vw.cimBin.XORBytes()
+	if err != nil {
+		dialog.ShowError(err, vw)
+		return false
+	}
+	if !vw.e.mw.saveFile("Save bin file", fmt.Sprintf("cim_%x_%s.bin", vw.cimBin.SnSticker, time.Now().Format("20060102-150405")), bin) {
+		return false
+	}
+	vw.logOp("Saved the image")
+	return true
+}
+
+// operation is a line of a viewer's own log.
+type operation struct {
+	time time.Time
+	text string
+}
+
+// logOp adds text to the viewer's log without writing it to the main one.
+func (vw *viewerWindow) logOp(text string) {
+	vw.opsMu.Lock()
+	defer vw.opsMu.Unlock()
+	vw.ops = append(vw.ops, operation{time: time.Now(), text: text})
+}
+
+// operations returns a copy of the viewer's log.
+func (vw *viewerWindow) operations() []operation {
+	vw.opsMu.Lock()
+	defer vw.opsMu.Unlock()
+	return slices.Clone(vw.ops)
+}
+
+// output writes to the main log like mainWindow.output and keeps the text in
+// the viewer's log, which the job report lists.
+func (vw *viewerWindow) output(format string, values ...any) {
+	text := fmt.Sprintf(format, values...)
+	vw.logOp(text)
+	vw.e.mw.output("%s", text)
 }
 
 func (vw *viewerWindow) closeIntercept() {
@@ -100,24 +192,23 @@ func kv(w fyne.Window, k, valueFormat string, values ...interface{}) *fyne.Conta
 
 func (vw *viewerWindow) newToolbar() *widget.Toolbar {
 	saveAction := widget.NewToolbarAction(theme.DocumentSaveIcon(), func() {
-		if vw.cimBin != nil {
-			bin, err := vw.cimBin.XORBytes()
-			if err != nil {
-				dialog.ShowError(err, vw)
-				return
-			}
-			vw.e.mw.saveFile("Save bin file", fmt.Sprintf("cim_%x_%s.bin", vw.cimBin.SnSticker, time.Now().Format("20060102-15_04_05")), bin)
-			vw.saved = true
+		if !vw.save() {
 			return
 		}
-		vw.e.mw.saveFile("Save raw bin file", fmt.Sprintf("cim_raw_%s.bin", time.Now().Format("20060102-15_04_05")), vw.data)
 		vw.saved = true
+		if vw.cimBin != nil && !vw.unparsed {
+			vw.info.markSaved()
+		}
 	})
 	writeAction := widget.NewToolbarAction(theme.UploadIcon(), func() {
 		if vw.cimBin == nil {
 			dialog.ShowError(errors.New("Not valid cim eeprom"), vw) //lint:ignore ST1005 ignore this error
 			return
 		}
+		if err := vw.editable(); err != nil {
+			dialog.ShowError(err, vw)
+			return
+		}
 		bin, err := vw.cimBin.XORBytes()
 		if err != nil {
 			dialog.ShowError(err, vw)
@@ -125,15 +216,17 @@ func (vw *viewerWindow) newToolbar() *widget.Toolbar {
 		}
 		dialog.ShowConfirm("Write to CIM?", "Continue writing to CIM?", func(ok bool) {
 			if ok {
-				vw.e.mw.output("Flashing CIM ... ")
+				vw.output("Flashing CIM ... ")
 				start := time.Now()
 				go func() {
 					vw.e.mw.disableButtons()
 					defer vw.e.mw.enableButtons()
-					if err := vw.e.mw.writeCIM(vw.e.port, bin); err != nil {
+					if _, err := vw.e.mw.writeCIM(vw.e.port, bin); err != nil {
+						vw.logOp("Write to the CIM on " + vw.e.port + " failed: " + err.Error())
 						fyne.Do(func() { dialog.ShowError(err, vw) })
 						return
 					}
+					vw.logOp("Wrote the image to the CIM on " + vw.e.port)
 					fyne.Do(func() {
 						dialog.ShowInformation("Write done", fmt.Sprintf("Write successfull, took %s", time.Since(start).Round(time.Millisecond).String()), vw)
 					})
@@ -163,89 +256,81 @@ func (vw *viewerWindow) newToolbar() *widget.Toolbar {
 	//	}
 	//})
 
-	//editAction := widget.NewToolbarAction(theme.WarningIcon(), func() {
-	//	vw.SetContent(newEditView(vw))
-	//})
+	editAction := widget.NewToolbarAction(theme.DocumentCreateIcon(), func() {
+		if vw.tabs != nil {
+			vw.tabs.Select(vw.editTab)
+		}
+	})
+
+	layoutAction := widget.NewToolbarAction(theme.ViewRestoreIcon(), vw.chooseLayout)
+	reportAction := widget.NewToolbarAction(theme.DocumentPrintIcon(), vw.exportReport)
 
 	toolbar := widget.NewToolbar(
 		//homeAction,
 		saveAction,
 		writeAction,
-		//widget.NewToolbarSeparator(),
+		widget.NewToolbarSeparator(),
+		layoutAction,
 	)
 
-	//toolbar.Append(widget.NewToolbarSpacer())
-	//toolbar.Append(editAction)
+	if vw.cimBin != nil {
+		toolbar.Append(reportAction)
+		toolbar.Append(widget.NewToolbarSpacer())
+		toolbar.Append(editAction)
+	}
 	return toolbar
 }
 
 func (vw *viewerWindow) layout() fyne.CanvasObject {
 	vw.toolbar = vw.newToolbar()
-	vw.infoTab = container.NewTabItemWithIcon("Info", theme.InfoIcon(), vw.renderInfoTab())
-	vw.versionTab = container.NewTabItemWithIcon("Versions", theme.QuestionIcon(), widget.NewForm(
+	vw.info = newInfoView(vw)
+	vw.infoTab = container.NewTabItemWithIcon("Info", theme.InfoIcon(), vw.info.layout())
+	vw.versionTab = container.NewTabItemWithIcon("Versions", theme.QuestionIcon(), vw.renderVersionTab())
+	vw.vinTab = container.NewTabItemWithIcon("VIN", theme.AccountIcon(), vw.renderVinTab())
+	vw.keys = newKeysView(vw)
+	keysTab := container.NewTabItemWithIcon("Keys", theme.LoginIcon(), vw.keys.layout())
+
+	vw.hexTab = container.NewTabItemWithIcon("Hex", theme.SearchIcon(), newHexView(vw))
+	vw.editor = newEditView(vw)
+	vw.editTab = container.NewTabItemWithIcon("Edit", theme.DocumentCreateIcon(), vw.editor.layout())
+	vw.checks = newCheckView(vw)
+	vw.checkTab = container.NewTabItemWithIcon("Checks", theme.ConfirmIcon(), vw.checks.layout())
+	vw.mirrors = newMirrorView(vw)
+	vw.mirrorTab = container.NewTabItemWithIcon("Mirrors", theme.ContentCopyIcon(), vw.mirrors.layout())
+	vw.tabs = container.NewAppTabs(vw.infoTab, vw.versionTab, vw.vinTab, keysTab, vw.hexTab, vw.editTab, vw.checkTab, vw.mirrorTab)
+	vw.tabs.OnSelected = func(t *container.TabItem) {
+		// Pick up changes made on the other tabs
+		if t == vw.editTab && !vw.editor.dirty() {
+			vw.editor.reload()
+		}
+	}
+
+	return container.NewBorder(vw.toolbar, nil, nil, nil,
+		vw.tabs,
+	)
+}
+
+func (vw *viewerWindow) renderVersionTab() fyne.CanvasObject {
+	return widget.NewForm(
 		widget.NewFormItem("End model (HW+SW)", widget.NewLabel(fmt.Sprintf("%d%s", vw.cimBin.PartNo1, vw.cimBin.PartNo1Rev))),
 		widget.NewFormItem("Base model (HW+boot)", widget.NewLabel(fmt.Sprintf("%d%s", vw.cimBin.PnBase1, vw.cimBin.PnBase1Rev))),
 		widget.NewFormItem("Delphi part number", widget.NewLabel(fmt.Sprintf("%d", vw.cimBin.DelphiPN))),
 		widget.NewFormItem("SAAB part number", widget.NewLabel(fmt.Sprintf("%d", vw.cimBin.PartNo))),
 		widget.NewFormItem("Configuration Version", widget.NewLabel(fmt.Sprintf("%d", vw.cimBin.ConfigurationVersion))),
-	))
-	vw.keyList = &widget.List{
-		Length: func() int {
-			return int(vw.cimBin.Keys.Count1)
-		},
-		CreateItem: func() fyne.CanvasObject {
-			return container.NewHBox(
-				&widget.Label{TextStyle: fyne.TextStyle{Bold: true}},
-				layout.NewSpacer(),
-				&widget.Label{TextStyle: fyne.TextStyle{Italic: true}},
-				&widget.Label{},
-				layout.NewSpacer(),
-				widget.NewButtonWithIcon("Show", theme.HelpIcon(), func() {}),
-				widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {}),
-			)
-		},
-		UpdateItem: func(item widget.ListItemID, obj fyne.CanvasObject) {
-			c, ok := obj.(*fyne.Container)
-			if ok {
-				c.Objects[0].(*widget.Label).SetText(fmt.Sprintf("Key #%d", item))
-				c.Objects[2].(*widget.Label).SetText(vw.cimBin.Keys.Data1[item].Type())
-				c.Objects[3].(*widget.Label).SetText(fmt.Sprintf("%02X", vw.cimBin.Keys.Data1[item].Value))
-				c.Objects[5].(*widget.Button).OnTapped = func() {
-					dialog.ShowCustom(fmt.Sprintf("Key #%d", item), "OK", newKeyView(vw.e, vw, item, vw.cimBin), vw)
-				}
-				obj.(*fyne.Container).Objects[6].(*widget.Button).OnTapped = func() {
-					vw.cimBin.Keys.Count1--
-					vw.cimBin.Keys.Count2--
-					vw.cimBin.DeleteKey(item)
-					var err error
-					vw.data, err = vw.cimBin.XORBytes()
-					if err != nil {
-						dialog.ShowError(err, vw)
-					}
-					vw.keyList.Refresh()
-				}
-			}
-		},
-	}
-
-	keysTab := container.NewTabItemWithIcon("Keys", theme.LoginIcon(),
-		container.NewBorder(
-			nil,
-			widget.NewButtonWithIcon("Add key", theme.ContentAddIcon(), func() {
-				vw.addKey()
-			}),
-			nil,
-			nil,
-			vw.keyList,
-		),
 	)
+}
 
-	hexTab := container.NewTabItemWithIcon("Hex", theme.SearchIcon(), newHexView(vw))
-	vw.tabs = container.NewAppTabs(vw.infoTab, vw.versionTab, keysTab, hexTab)
-
-	return container.NewBorder(vw.toolbar, nil, nil, nil,
-		vw.tabs,
-	)
+// refreshTabs redraws every tab from vw.cimBin and vw.data after the image
+// was replaced.
+func (vw *viewerWindow) refreshTabs() {
+	vw.info.reload()
+	vw.versionTab.Content = vw.renderVersionTab()
+	vw.vinTab.Content = vw.renderVinTab()
+	vw.hexEditor.Reload(vw.data)
+	vw.checks.reload()
+	vw.mirrors.reload()
+	vw.keys.refresh()
+	vw.tabs.Refresh()
 }
 
 // fixedWidth forces obj to a fixed width (keeping its natural height) so form
@@ -265,189 +350,3 @@ func hexValidator(length int) func(s string) error {
 		return nil
 	}
 }
-
-func (vw *viewerWindow) renderInfoTab() fyne.CanvasObject {
-	vButton := widget.NewButtonWithIcon("Virginize", theme.SearchReplaceIcon(), func() {
-		vw.cimBin.Unmarry()
-		vw.infoTab.Content = vw.renderInfoTab()
-		dialog.ShowInformation("Virginization complete", "The file has been virginized.\nNow flash the eeprom, re-assemble the car and add the CIM with Tech2", vw)
-		vw.askSaveOnClose = true
-		vw.saved = false
-	})
-
-	iskEntry := &widget.Entry{
-		Text:      fmt.Sprintf("%X%X", vw.cimBin.Keys.IskHI1, vw.cimBin.Keys.IskLO1),
-		Wrapping:  fyne.TextWrapOff,
-		Validator: hexValidator(12),
-	}
-	iskEntry.OnChanged = func(s string) {
-		if len(s) > 12 {
-			iskEntry.SetText(s[:12])
-		}
-		if len(s) == 12 {
-			if err := vw.cimBin.Keys.SetISKHigh(s[:8]); err != nil {
-				dialog.ShowError(err, vw)
-				return
-			}
-			if err := vw.cimBin.Keys.SetISKLow(s[8:12]); err != nil {
-				dialog.ShowError(err, vw)
-				return
-			}
-		}
-	}
-
-	pskEntry := &widget.Entry{
-		Text:      fmt.Sprintf("%X%X", vw.cimBin.PSK.High, vw.cimBin.PSK.Low),
-		Wrapping:  fyne.TextWrapOff,
-		Validator: hexValidator(12),
-	}
-	pskEntry.OnChanged = func(s string) {
-		if len(s) > 12 {
-			pskEntry.SetText(s[:12])
-		}
-		if len(s) == 12 {
-			decoded, err := hex.DecodeString(s)
-			if err != nil {
-				dialog.ShowError(err, vw)
-				return
-			}
-			if err := vw.cimBin.PSK.SetHigh(decoded[:4]); err != nil {
-				dialog.ShowError(err, vw)
-				return
-			}
-			if err := vw.cimBin.PSK.SetLow(decoded[4:6]); err != nil {
-				dialog.ShowError(err, vw)
-				return
-			}
-		}
-	}
-
-	vinEntry := &widget.Entry{Text: vw.cimBin.Vin.Data}
-	vinEntry.OnChanged = func(s string) {
-		if len(s) > 17 {
-			vinEntry.SetText(s[:17])
-		}
-		if len(s) == 17 {
-			vw.cimBin.Vin.Data = s
-			if err := vw.cimBin.Vin.Set(s); err != nil {
-				dialog.ShowError(err, vw)
-			}
-		}
-	}
-
-	pinHex := &widget.Label{Text: fmt.Sprintf("%X", vw.cimBin.Pin.Data1)}
-
-	pinEntry := widget.NewEntry()
-
-	if bytes.Equal(vw.cimBin.Pin.Data1, []byte{0xFF, 0xFF, 0xFF, 0xFF}) {
-		pinEntry.SetPlaceHolder("not set")
-	} else {
-		pinEntry.SetText(string(vw.cimBin.Pin.Data1[:]))
-	}
-
-	pinEntry.OnChanged = func(s string) {
-		if len(s) > 4 {
-			pinEntry.SetText(s[:4])
-		}
-		if len(s) == 4 {
-			if err := vw.cimBin.SetPin(fmt.Sprintf("%X", s)); err != nil {
-				dialog.ShowError(err, vw)
-			}
-			pinHex.SetText(fmt.Sprintf("%X", vw.cimBin.Pin.Data1))
-		}
-	}
-
-	sasSelect := &widget.Select{
-		Options: []string{"Yes", "No"},
-		OnChanged: func(s string) {
-			vw.cimBin.SetSasOpt(s == "Yes")
-		},
-		Selected: func() string {
-			if vw.cimBin.GetSasOpt() {
-				return "Yes"
-			}
-			return "No"
-		}(),
-	}
-
-	snstickerEntry := &widget.Entry{Text: fmt.Sprintf("%X", vw.cimBin.SnSticker)}
-	snstickerEntry.OnChanged = func(s string) {
-		if len(s) > 10 {
-			snstickerEntry.SetText(s[:10])
-			return
-		}
-		if len(s) == 10 {
-			b, err := hex.DecodeString(s)
-			if err != nil {
-				dialog.ShowError(err, vw)
-				return
-			}
-			vw.cimBin.SnSticker = b
-		}
-	}
-
-	form := widget.NewForm(
-		widget.NewFormItem("MD5", container.NewHBox(
-			widget.NewLabel(vw.cimBin.MD5()),
-			layout.NewSpacer(),
-			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
-				vw.e.App.Clipboard().SetContent(vw.cimBin.MD5())
-			}),
-		)),
-		widget.NewFormItem("CRC32", container.NewHBox(
-			widget.NewLabel(vw.cimBin.CRC32()),
-			layout.NewSpacer(),
-			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
-				vw.e.App.Clipboard().SetContent(vw.cimBin.CRC32())
-			}),
-		)),
-		widget.NewFormItem("S/N Sticker", container.NewHBox(
-			fixedWidth(100, snstickerEntry),
-			layout.NewSpacer(),
-			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
-				vw.e.App.Clipboard().SetContent(vinEntry.Text)
-			}),
-		)),
-		widget.NewFormItem("VIN", container.NewHBox(
-			fixedWidth(150, vinEntry),
-			container.NewHBox(
-				widget.NewLabelWithStyle("MY:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-				widget.NewLabelWithStyle(vw.cimBin.ModelYear(), fyne.TextAlignLeading, fyne.TextStyle{}),
-			),
-			layout.NewSpacer(),
-			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
-				vw.e.App.Clipboard().SetContent(vinEntry.Text)
-			}),
-		)),
-		widget.NewFormItem("PIN", container.NewHBox(
-			fixedWidth(100, pinEntry),
-			layout.NewSpacer(),
-			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
-				vw.e.App.Clipboard().SetContent(pinEntry.Text)
-			}),
-		)),
-		widget.NewFormItem("PIN (hex)", container.NewHBox(
-			pinHex,
-			layout.NewSpacer(),
-			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
-				vw.e.App.Clipboard().SetContent(fmt.Sprintf("%X", pinHex.Text))
-			}),
-		)),
-		widget.NewFormItem("SAS", sasSelect),
-		widget.NewFormItem("ISK", container.NewHBox(
-			fixedWidth(150, iskEntry),
-			layout.NewSpacer(),
-			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
-				vw.e.App.Clipboard().SetContent(iskEntry.Text)
-			}),
-		)),
-		widget.NewFormItem("PSK", container.NewHBox(
-			fixedWidth(150, pskEntry),
-			layout.NewSpacer(),
-			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
-				vw.e.App.Clipboard().SetContent(pskEntry.Text)
-			}),
-		)),
-	)
-	return container.NewBorder(nil, vButton, nil, nil, form)
-}
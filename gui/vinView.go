@@ -0,0 +1,78 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/cim/pkg/cim"
+	"github.com/roffe/eep/vin"
+)
+
+func (vw *viewerWindow) renderVinTab() fyne.CanvasObject {
+	info, err := vin.Decode(vw.cimBin.Vin.Data)
+	if err != nil {
+		return container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("VIN %q can't be decoded", vw.cimBin.Vin.Data)),
+			widget.NewLabel(err.Error()),
+		)
+	}
+	check := string(info.Check) + ", correct"
+	if !info.CheckOK() {
+		check = fmt.Sprintf("%c, expected %c", info.Check, info.Expected)
+	}
+	year := "unknown"
+	if info.Year != 0 {
+		year = strconv.Itoa(info.Year)
+	}
+	label := func(s string) *widget.Label {
+		return &widget.Label{Text: s, Truncation: fyne.TextTruncateEllipsis}
+	}
+	form := widget.NewForm(
+		widget.NewFormItem("VIN", &widget.Label{Text: info.VIN, TextStyle: fyne.TextStyle{Monospace: true}}),
+		widget.NewFormItem("Manufacturer", label(fmt.Sprintf("%s (%s)", info.Manufacturer, info.WMI))),
+		widget.NewFormItem("Model line", label(info.Line)),
+		widget.NewFormItem("Series", label(info.Series)),
+		widget.NewFormItem("Body style", label(info.Body)),
+		widget.NewFormItem("Restraint system", label(info.Restraint)),
+		widget.NewFormItem("Engine", label(info.Engine)),
+		widget.NewFormItem("Check digit", label(check)),
+		widget.NewFormItem("Model year", label(year)),
+		widget.NewFormItem("Plant", label(info.Plant)),
+		widget.NewFormItem("Serial", label(info.Serial)),
+	)
+	if !info.CheckOK() {
+		return container.NewBorder(nil, widget.NewLabel("The check digit doesn't match, the VIN may be mistyped."), nil, nil, form)
+	}
+	return form
+}
+
+// setVIN stores a VIN typed into the Info tab, asking first when its check
+// digit is wrong.
+func (vw *viewerWindow) setVIN(s string) {
+	s = strings.ToUpper(s)
+	set := func() {
+		vw.info.change("Set VIN to "+s, func(bin *cim.Bin) error {
+			bin.Vin.Data = s
+			return bin.Vin.Set(s)
+		})
+	}
+	err := vin.Validate(s)
+	switch {
+	case err == nil:
+		set()
+	case errors.Is(err, vin.ErrCheckDigit):
+		dialog.ShowConfirm("Invalid VIN check digit", fmt.Sprintf("%s.\nThe VIN may be mistyped, store it anyway?", err), func(ok bool) {
+			if ok {
+				set()
+			}
+		}, vw)
+	default:
+		dialog.ShowError(err, vw)
+	}
+}
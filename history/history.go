@@ -0,0 +1,173 @@
+// Package history keeps a local record of every read, write and erase, with
+// a copy of the image involved, so a CIM can be looked up long after the job.
+//
+// Entries are appended to a JSON Lines index next to a directory of images,
+// which survives crashes mid-write better than rewriting a single file.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Op is the kind of operation an entry records.
+type Op string
+
+const (
+	Read  Op = "read"
+	Write Op = "write"
+	Erase Op = "erase"
+)
+
+// Entry is one recorded operation.
+type Entry struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Op        Op        `json:"op"`
+	Port      string    `json:"port,omitempty"`
+	Firmware  string    `json:"firmware,omitempty"`
+	VIN       string    `json:"vin,omitempty"`
+	SnSticker string    `json:"sn_sticker,omitempty"`
+	MD5       string    `json:"md5,omitempty"`
+	CRC32     string    `json:"crc32,omitempty"`
+	// Valid is whether the image loaded and validated as a CIM.
+	Valid bool `json:"valid"`
+	// Status is "ok" or the error the operation or validation failed with.
+	Status string `json:"status"`
+	// Image is the file name of the stored image, empty without one.
+	Image string `json:"image,omitempty"`
+}
+
+// OK reports whether the operation succeeded.
+func (e Entry) OK() bool {
+	return e.Status == "ok"
+}
+
+// Matches reports whether every word of query is found in one of the
+// entry's fields, ignoring case. An empty query matches everything.
+func (e Entry) Matches(query string) bool {
+	hay := strings.ToLower(strings.Join([]string{
+		e.Time.Local().Format("2006-01-02 15:04:05"), string(e.Op), e.Port, e.Firmware,
+		e.VIN, e.SnSticker, e.MD5, e.CRC32, e.Status,
+	}, " "))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(hay, word) {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	indexFile = "history.jsonl"
+	imageDir  = "images"
+)
+
+// ErrNoImage is returned by Image for entries recorded without one.
+var ErrNoImage = errors.New("entry has no image")
+
+// Store is a history directory. It is safe for concurrent use.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// Open opens the history in dir, creating it if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, imageDir), 0755); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	return &Store{dir: dir, now: time.Now}, nil
+}
+
+// Dir returns the directory the history is kept in.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Add records e, storing image with it when not nil. ID, Time and Image
+// are filled in and the stored entry is returned.
+func (s *Store) Add(e Entry, image []byte) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.Time = s.now()
+	e.ID = fmt.Sprintf("%s-%s", e.Time.UTC().Format("20060102T150405.000000000"), e.Op)
+	if image != nil {
+		e.Image = e.ID + ".bin"
+		if err := os.WriteFile(filepath.Join(s.dir, imageDir, e.Image), image, 0644); err != nil {
+			return e, fmt.Errorf("history: %w", err)
+		}
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, indexFile), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return e, fmt.Errorf("history: %w", err)
+	}
+	// Finish a line cut short by a crash so it doesn't swallow this one
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return e, fmt.Errorf("history: %w", err)
+	}
+	return e, f.Close()
+}
+
+// List returns the entries matching query, newest first. Lines that don't
+// parse, like one cut short by a crash, are skipped.
+func (s *Store) List(query string) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := os.ReadFile(filepath.Join(s.dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	var entries []Entry
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		if e.Matches(query) {
+			entries = append(entries, e)
+		}
+	}
+	slices.Reverse(entries)
+	return entries, sc.Err()
+}
+
+// Image returns the image stored with e.
+func (s *Store) Image(e Entry) ([]byte, error) {
+	if e.Image == "" {
+		return nil, ErrNoImage
+	}
+	b, err := os.ReadFile(filepath.Join(s.dir, imageDir, filepath.Base(e.Image)))
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,95 @@
+package history
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	if entries, err := s.List(""); err != nil || len(entries) != 0 {
+		t.Fatalf("empty history = %v, %v", entries, err)
+	}
+
+	image := []byte{1, 2, 3}
+	read, err := s.Add(Entry{Op: Read, Port: "COM3", VIN: "YS3FD49Y881234567", SnSticker: "1234567890", Valid: true, Status: "ok"}, image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(Entry{Op: Erase, Port: "COM3", Status: "ok"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(Entry{Op: Write, Port: "COM4", VIN: "YS3EF55E123456789", Status: "timeout"}, image); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := s.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Op != Write || all[2].Op != Read {
+		t.Fatalf("List = %+v, want newest first", all)
+	}
+	if all[0].OK() || !all[1].OK() {
+		t.Error("OK")
+	}
+
+	for query, want := range map[string]int{
+		"ys3fd49y":     1,
+		"com3":         2,
+		"com3 erase":   1,
+		"timeout":      1,
+		"2026-03-04":   3,
+		"1234567890":   1,
+		"nothing like": 0,
+	} {
+		got, err := s.List(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != want {
+			t.Errorf("List(%q) = %d entries, want %d", query, len(got), want)
+		}
+	}
+
+	b, err := s.Image(read)
+	if err != nil || !bytes.Equal(b, image) {
+		t.Errorf("Image = %v, %v", b, err)
+	}
+	if _, err := s.Image(all[1]); !errors.Is(err, ErrNoImage) {
+		t.Errorf("Image without one = %v", err)
+	}
+
+	// A line cut short by a crash is skipped
+	f, err := os.OpenFile(filepath.Join(s.Dir(), indexFile), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"broken","op":"re`)
+	f.Close()
+	if got, err := s.List(""); err != nil || len(got) != 3 {
+		t.Errorf("List after truncated line = %d entries, %v", len(got), err)
+	}
+
+	// and doesn't take the next entry down with it
+	if _, err := s.Add(Entry{Op: Read, Port: "COM5", Status: "ok"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.List("")
+	if err != nil || len(got) != 4 || got[0].Port != "COM5" {
+		t.Errorf("List after adding to a truncated line = %+v, %v", got, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"fyne.io/fyne/v2/app"
 	"github.com/roffe/eep/gui"
+	"github.com/roffe/eep/update"
 )
 
 func init() {
@@ -21,7 +22,12 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	//application.Lifecycle().SetOnStarted(ui.CheckUpdate)
+	application.Lifecycle().SetOnStarted(func() {
+		if err := update.ConfirmStartup(); err != nil {
+			log.Println(err)
+		}
+		ui.CheckUpdate()
+	})
 	//application.Run()
 	ui.Run()
 }
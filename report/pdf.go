@@ -0,0 +1,342 @@
+package report
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A4 page geometry in points.
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 50.0
+
+	logoMaxWidth  = 160.0
+	logoMaxHeight = 56.0
+
+	bodySize  = 9.0
+	lineSpace = 1.35
+	// Courier glyphs are all 0.6 em wide, which makes wrapping exact.
+	courierWidth = 0.6
+)
+
+// Font resource names, in the order the font objects are written.
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+	fontMono    = "F3"
+	fontMonoB   = "F4"
+)
+
+var baseFonts = []string{"Helvetica", "Helvetica-Bold", "Courier", "Courier-Bold"}
+
+type pdfLogo struct {
+	width, height int
+	data          []byte // zlib compressed RGB
+}
+
+// pdfDoc lays text out top to bottom over as many pages as it needs.
+type pdfDoc struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+	logo  *pdfLogo
+}
+
+// PDF renders the report as a PDF document using the standard fonts.
+func (r *Report) PDF() ([]byte, error) {
+	d := &pdfDoc{}
+	if len(r.Logo) > 0 {
+		logo, err := decodeLogo(r.Logo)
+		if err != nil {
+			return nil, err
+		}
+		d.logo = logo
+	}
+	d.newPage()
+
+	logoBottom := d.y
+	if d.logo != nil {
+		w, h := float64(d.logo.width), float64(d.logo.height)
+		scale := min(logoMaxWidth/w, logoMaxHeight/h)
+		w, h = w*scale, h*scale
+		fmt.Fprintf(d.page, "q %.2f 0 0 %.2f %.2f %.2f cm /Logo Do Q\n", w, h, pageWidth-margin-w, d.y-h)
+		logoBottom = d.y - h
+	}
+	if r.Shop != "" {
+		d.line(fontBold, 14, margin, r.Shop)
+	}
+	d.line(fontBold, 18, margin, r.Title)
+	d.line(fontRegular, bodySize, margin, "Created "+r.Created.Format(time.RFC1123))
+	d.y = min(d.y, logoBottom) - 4
+	fmt.Fprintf(d.page, "%.2f w %.2f %.2f m %.2f %.2f l S\n", 1.5, margin, d.y, pageWidth-margin, d.y)
+	d.y -= 8
+
+	for _, s := range r.Sections {
+		d.section(s)
+	}
+	return d.bytes(), nil
+}
+
+func decodeLogo(b []byte) (*pdfLogo, error) {
+	if _, err := logoType(b); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLogo, err)
+	}
+	bounds := img.Bounds()
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	row := make([]byte, 0, bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row = row[:0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Composite over white, PDF images here have no alpha
+			r, g, b, a := img.At(x, y).RGBA()
+			white := 0xffff - a
+			row = append(row, byte((r+white)>>8), byte((g+white)>>8), byte((b+white)>>8))
+		}
+		zw.Write(row)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return &pdfLogo{width: bounds.Dx(), height: bounds.Dy(), data: buf.Bytes()}, nil
+}
+
+func (d *pdfDoc) newPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+	d.y = pageHeight - margin
+}
+
+// need starts a new page unless height more points fit on this one.
+func (d *pdfDoc) need(height float64) {
+	if d.y-height < margin {
+		d.newPage()
+	}
+}
+
+func (d *pdfDoc) text(font string, size, x, y float64, s string) {
+	fmt.Fprintf(d.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+// line writes one line of text at the cursor and moves the cursor down.
+func (d *pdfDoc) line(font string, size, x float64, s string) {
+	d.need(size * lineSpace)
+	d.y -= size * lineSpace
+	d.text(font, size, x, d.y, s)
+}
+
+func (d *pdfDoc) section(s Section) {
+	// Keep the heading with the first line of the section
+	d.need(13*lineSpace + 8 + 2*bodySize*lineSpace)
+	d.y -= 8
+	d.line(fontBold, 13, margin, s.Title)
+	d.y -= 2
+
+	lineHeight := bodySize * lineSpace
+	charWidth := bodySize * courierWidth
+	if len(s.Fields) > 0 {
+		labelChars := 0
+		for _, f := range s.Fields {
+			labelChars = max(labelChars, len(f.Label))
+		}
+		valueX := margin + float64(labelChars+2)*charWidth
+		width := int((pageWidth - margin - valueX) / charWidth)
+		for _, f := range s.Fields {
+			lines := wrap(f.Value, width)
+			d.need(float64(len(lines)) * lineHeight)
+			for i, l := range lines {
+				d.y -= lineHeight
+				if i == 0 {
+					d.text(fontBold, bodySize, margin, d.y, f.Label)
+				}
+				d.text(fontMono, bodySize, valueX, d.y, l)
+			}
+		}
+	}
+
+	if len(s.Rows) > 0 {
+		widths := columnWidths(s.Columns, s.Rows, int((pageWidth-2*margin)/charWidth))
+		d.row(fontMonoB, s.Columns, widths)
+		for _, row := range s.Rows {
+			d.row(fontMono, row, widths)
+		}
+	}
+
+	if len(s.Fields) == 0 && len(s.Rows) == 0 && s.Empty != "" {
+		d.line(fontRegular, bodySize, margin, s.Empty)
+	}
+}
+
+// row writes one table row, wrapping cells to their column width.
+func (d *pdfDoc) row(font string, cells []string, widths []int) {
+	lineHeight := bodySize * lineSpace
+	charWidth := bodySize * courierWidth
+	var wrapped [][]string
+	height := 1
+	for i, w := range widths {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		lines := wrap(cell, w)
+		wrapped = append(wrapped, lines)
+		height = max(height, len(lines))
+	}
+	d.need(float64(height) * lineHeight)
+	for l := range height {
+		d.y -= lineHeight
+		x := margin
+		for i, lines := range wrapped {
+			if l < len(lines) {
+				d.text(font, bodySize, x, d.y, lines[l])
+			}
+			x += float64(widths[i]+columnGap) * charWidth
+		}
+	}
+	d.y -= 2
+}
+
+const columnGap = 2
+
+// columnWidths shares total characters between the columns. Narrow columns
+// get their full width, the rest split what is left evenly.
+func columnWidths(columns []string, rows [][]string, total int) []int {
+	natural := make([]int, len(columns))
+	for i, c := range columns {
+		natural[i] = len(c)
+	}
+	for _, row := range rows {
+		for i := range min(len(row), len(natural)) {
+			natural[i] = max(natural[i], len(row[i]))
+		}
+	}
+	order := make([]int, len(natural))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return natural[order[a]] < natural[order[b]] })
+
+	widths := make([]int, len(natural))
+	left := total - columnGap*(len(natural)-1)
+	for n, i := range order {
+		widths[i] = max(1, min(natural[i], left/(len(order)-n)))
+		left -= widths[i]
+	}
+	return widths
+}
+
+// wrap splits s into lines of at most width characters, at spaces where it
+// can.
+func wrap(s string, width int) []string {
+	s = strings.Join(strings.Fields(s), " ")
+	if width < 1 || len(s) <= width {
+		return []string{s}
+	}
+	var lines []string
+	for len(s) > width {
+		cut := strings.LastIndexByte(s[:width+1], ' ')
+		if cut <= 0 {
+			lines = append(lines, s[:width])
+			s = s[width:]
+			continue
+		}
+		lines = append(lines, s[:cut])
+		s = s[cut+1:]
+	}
+	return append(lines, s)
+}
+
+// pdfString escapes s for a literal string in WinAnsiEncoding. Characters
+// outside Latin-1 become '?'.
+func pdfString(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20:
+			sb.WriteByte(' ')
+		case r < 0x80:
+			sb.WriteRune(r)
+		case r < 0x100:
+			fmt.Fprintf(&sb, "\\%03o", r)
+		default:
+			sb.WriteByte('?')
+		}
+	}
+	return sb.String()
+}
+
+// bytes writes the document: catalog, page tree, fonts, the logo, then a
+// content stream and page object per page.
+func (d *pdfDoc) bytes() []byte {
+	const (
+		catalogObj = 1
+		pagesObj   = 2
+		fontObj    = 3
+	)
+	logoObj := fontObj + len(baseFonts)
+	firstPage := logoObj
+	if d.logo != nil {
+		firstPage++
+	}
+
+	var objects [][]byte
+	add := func(format string, args ...any) {
+		objects = append(objects, fmt.Appendf(nil, format, args...))
+	}
+
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+2*i+1))
+	}
+	add("<< /Type /Catalog /Pages %d 0 R >>", pagesObj)
+	add("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+
+	var fonts []string
+	for i, name := range baseFonts {
+		add("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name)
+		fonts = append(fonts, fmt.Sprintf("/F%d %d 0 R", i+1, fontObj+i))
+	}
+	resources := fmt.Sprintf("/Font << %s >>", strings.Join(fonts, " "))
+	if d.logo != nil {
+		add("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			d.logo.width, d.logo.height, len(d.logo.data), d.logo.data)
+		resources += fmt.Sprintf(" /XObject << /Logo %d 0 R >>", logoObj)
+	}
+
+	for i, p := range d.pages {
+		add("<< /Length %d >>\nstream\n%s\nendstream", p.Len(), p.Bytes())
+		add("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.0f %.0f] /Resources << %s >> /Contents %d 0 R >>",
+			pagesObj, pageWidth, pageHeight, resources, firstPage+2*i)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, catalogObj, xref)
+	return out.Bytes()
+}
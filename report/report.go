@@ -0,0 +1,134 @@
+// Package report renders job reports, the printable record of what was done
+// to a CIM, as HTML and PDF.
+package report
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// Report is the record of one job.
+type Report struct {
+	Title   string
+	Shop    string
+	Logo    []byte // PNG, JPEG or GIF, optional
+	Created time.Time
+	// Sections are rendered in order.
+	Sections []Section
+}
+
+// Section is a titled block of label/value fields, a table, or both.
+type Section struct {
+	Title   string
+	Fields  []Field
+	Columns []string
+	Rows    [][]string
+	// Empty is shown when the section has neither fields nor rows.
+	Empty string
+}
+
+// Field is one labelled value.
+type Field struct {
+	Label string
+	Value string
+}
+
+// ErrLogo is returned for logos that aren't a supported image.
+var ErrLogo = errors.New("logo must be a PNG, JPEG or GIF image")
+
+func logoType(b []byte) (string, error) {
+	switch ct := http.DetectContentType(b); ct {
+	case "image/png", "image/jpeg", "image/gif":
+		return ct, nil
+	}
+	return "", ErrLogo
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 10pt; margin: 2em; color: #000; }
+header { display: flex; justify-content: space-between; align-items: flex-start; border-bottom: 2px solid #000; margin-bottom: 1em; }
+header img { max-height: 64px; max-width: 200px; }
+.shop { font-size: 14pt; font-weight: bold; }
+h1 { font-size: 18pt; margin: 0.2em 0; }
+h2 { font-size: 13pt; margin: 1.2em 0 0.4em; }
+table { border-collapse: collapse; }
+th, td { text-align: left; vertical-align: top; padding: 2px 8px 2px 0; }
+td { font-family: "Courier New", monospace; word-break: break-all; }
+table.grid th { border-bottom: 1px solid #000; }
+table.grid td { border-bottom: 1px solid #ccc; }
+.created, .empty { color: #444; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<header>
+<div>
+{{- with .Shop}}<div class="shop">{{.}}</div>{{end}}
+<h1>{{.Title}}</h1>
+<div class="created">Created {{.Created}}</div>
+</div>
+{{- with .Logo}}<img src="{{.}}" alt="">{{end}}
+</header>
+{{- range .Sections}}
+<section>
+<h2>{{.Title}}</h2>
+{{- if .Fields}}
+<table class="fields">
+{{- range .Fields}}
+<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Rows}}
+<table class="grid">
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if and (not .Fields) (not .Rows)}}
+<p class="empty">{{.Empty}}</p>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+// HTML renders the report as a standalone HTML page with the logo inlined.
+func (r *Report) HTML() ([]byte, error) {
+	data := struct {
+		Title    string
+		Shop     string
+		Logo     template.URL
+		Created  string
+		Sections []Section
+	}{
+		Title:    r.Title,
+		Shop:     r.Shop,
+		Created:  r.Created.Format(time.RFC1123),
+		Sections: r.Sections,
+	}
+	if len(r.Logo) > 0 {
+		ct, err := logoType(r.Logo)
+		if err != nil {
+			return nil, err
+		}
+		data.Logo = template.URL("data:" + ct + ";base64," + base64.StdEncoding.EncodeToString(r.Logo))
+	}
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
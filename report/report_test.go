@@ -0,0 +1,129 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	return &Report{
+		Title:   "CIM job report",
+		Shop:    "Saab & Sons (Göteborg)",
+		Created: time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+		Sections: []Section{
+			{Title: "Vehicle", Fields: []Field{
+				{Label: "VIN", Value: "YS3FD49Y881234567"},
+				{Label: "PIN", Value: "set"},
+			}},
+			{Title: "Keys", Columns: []string{"#", "IDE", "Type"}, Rows: [][]string{
+				{"0", "1A2B3C4D", "<remote>"},
+			}},
+			{Title: "Operations", Empty: "No operations logged"},
+		},
+	}
+}
+
+func testLogo(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHTML(t *testing.T) {
+	r := testReport()
+	r.Logo = testLogo(t)
+	b, err := r.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{
+		"Saab &amp; Sons (Göteborg)",
+		"YS3FD49Y881234567",
+		"&lt;remote&gt;",
+		"No operations logged",
+		`src="data:image/png;base64,`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML lacks %q", want)
+		}
+	}
+
+	r.Logo = []byte("not an image")
+	if _, err := r.HTML(); !errors.Is(err, ErrLogo) {
+		t.Errorf("HTML with bad logo = %v", err)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	r := testReport()
+	r.Logo = testLogo(t)
+	for i := range 200 {
+		r.Sections[1].Rows = append(r.Sections[1].Rows, []string{strconv.Itoa(i), fmt.Sprintf("%08X", i), "remote"})
+	}
+	b, err := r.PDF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("%PDF-1.4")) || !bytes.HasSuffix(b, []byte("%%EOF\n")) {
+		t.Fatal("not a PDF")
+	}
+	for _, want := range []string{
+		"(YS3FD49Y881234567) Tj",
+		`(Saab & Sons \(G\366teborg\)) Tj`,
+		"/Subtype /Image /Width 4 /Height 2",
+	} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("PDF lacks %q", want)
+		}
+	}
+	if n := bytes.Count(b, []byte("/Type /Page ")); n < 2 {
+		t.Errorf("%d pages, want the table to overflow onto a second", n)
+	}
+
+	// Every xref entry must point at its object
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(b)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	entries := strings.Split(string(b[xref:]), "\n")[3:]
+	for i, e := range entries {
+		if !strings.HasSuffix(e, " n ") {
+			break
+		}
+		off, _ := strconv.Atoi(e[:10])
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(b[off:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, b[off:off+10])
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	for _, tc := range []struct {
+		s     string
+		width int
+		want  []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"two words here", 9, []string{"two words", "here"}},
+		{"ABCDEFGHIJ", 4, []string{"ABCD", "EFGH", "IJ"}},
+	} {
+		if got := wrap(tc.s, tc.width); strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("wrap(%q, %d) = %q, want %q", tc.s, tc.width, got, tc.want)
+		}
+	}
+}
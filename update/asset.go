@@ -0,0 +1,72 @@
+package update
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	osAliases = map[string][]string{
+		"windows": {"windows", "win", "win32", "win64"},
+		"linux":   {"linux"},
+		"darwin":  {"darwin", "macos", "mac", "osx"},
+	}
+	archAliases = map[string][]string{
+		"amd64": {"amd64", "x64"},
+		"386":   {"386", "i386", "x86"},
+		"arm64": {"arm64", "aarch64"},
+	}
+	archiveSuffixes = []string{".zip", ".tar.gz", ".tgz", ".exe"}
+)
+
+// Asset picks the release asset built for goos/goarch. Names without an OS
+// part, like Saab_CIM_Tool_x64.zip, are the Windows builds.
+func (r *Release) Asset(goos, goarch string) (*Assets, error) {
+	for i := range r.Assets {
+		a := &r.Assets[i]
+		if !isArchive(a.Name) {
+			continue
+		}
+		tokens := nameTokens(a.Name)
+		assetOS := matchAlias(tokens, osAliases)
+		if assetOS == "" {
+			assetOS = "windows"
+		}
+		if assetOS == goos && matchAlias(tokens, archAliases) == goarch {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("release %s has no download for %s/%s", r.TagName, goos, goarch)
+}
+
+func isArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameTokens splits an asset name into lower case words. x86_64 is turned
+// into amd64 first so it isn't split and mistaken for x86.
+func nameTokens(name string) []string {
+	name = strings.ReplaceAll(strings.ToLower(name), "x86_64", "amd64")
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.' || r == ' '
+	})
+}
+
+func matchAlias(tokens []string, aliases map[string][]string) string {
+	for _, t := range tokens {
+		for name, list := range aliases {
+			for _, alias := range list {
+				if t == alias {
+					return name
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"golang.org/x/mod/semver"
+)
+
+// Channel selects which releases an update check considers.
+type Channel string
+
+const (
+	// Stable only offers full releases.
+	Stable Channel = "stable"
+	// Beta also offers prereleases, for testers.
+	Beta Channel = "beta"
+)
+
+// ErrNoRelease is returned when a channel has no usable release.
+var ErrNoRelease = errors.New("no release found")
+
+// ParseChannel returns the channel named s, defaulting to Stable.
+func ParseChannel(s string) Channel {
+	if Channel(s) == Beta {
+		return Beta
+	}
+	return Stable
+}
+
+// Includes reports whether rel is offered on the channel. Drafts and tags
+// that aren't valid semver never are.
+func (ch Channel) Includes(rel *Release) bool {
+	if rel.Draft || !semver.IsValid(rel.TagName) {
+		return false
+	}
+	return ch == Beta || !rel.Prerelease
+}
+
+// Filter returns the releases offered on the channel, newest version first.
+func (ch Channel) Filter(releases []*Release) []*Release {
+	var out []*Release
+	for _, rel := range releases {
+		if ch.Includes(rel) {
+			out = append(out, rel)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b *Release) int {
+		return semver.Compare(b.TagName, a.TagName)
+	})
+	return out
+}
+
+// LatestIn returns the release with the highest version on the channel.
+func (c *Client) LatestIn(ctx context.Context, ch Channel) (*Release, error) {
+	releases, err := c.Releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	offered := ch.Filter(releases)
+	if len(offered) == 0 {
+		return nil, fmt.Errorf("%s channel: %w", ch, ErrNoRelease)
+	}
+	return offered[0], nil
+}
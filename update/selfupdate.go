@@ -0,0 +1,271 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// markerEnv tells a freshly installed binary where to report that it started.
+const markerEnv = "EEP_UPDATE_MARKER"
+
+// Staged is a new executable written next to the running one, ready to be
+// swapped in.
+type Staged struct {
+	exe     string // path of the running executable
+	newPath string // staged new executable
+	oldPath string // where the running executable is moved on swap
+}
+
+// Executable returns the path of the running binary with symlinks resolved.
+func Executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// Stage extracts the executable from a downloaded release archive (zip,
+// tar.gz or a bare .exe) into exe+".new". Staging in the same directory keeps
+// the later swap a plain rename.
+func Stage(exe, archive string) (*Staged, error) {
+	s := &Staged{
+		exe:     exe,
+		newPath: exe + ".new",
+		oldPath: exe + ".old",
+	}
+	out, err := os.OpenFile(s.newPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return nil, err
+	}
+	if err := extractExecutable(archive, filepath.Base(exe), out); err != nil {
+		out.Close()
+		os.Remove(s.newPath)
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(s.newPath)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Swap moves the running executable aside and the staged one in its place.
+// Windows allows renaming a running executable, just not overwriting it.
+func (s *Staged) Swap() error {
+	os.Remove(s.oldPath) // left over from an earlier update
+	if err := os.Rename(s.exe, s.oldPath); err != nil {
+		return fmt.Errorf("move current executable aside: %w", err)
+	}
+	if err := os.Rename(s.newPath, s.exe); err != nil {
+		if rerr := os.Rename(s.oldPath, s.exe); rerr != nil {
+			return fmt.Errorf("install new executable: %v, and restoring the old one failed: %w", err, rerr)
+		}
+		return fmt.Errorf("install new executable: %w", err)
+	}
+	return nil
+}
+
+// Rollback puts the previous executable back after a Swap. If that fails the
+// new one is put back, so there is always an executable to start.
+func (s *Staged) Rollback() error {
+	if err := os.Rename(s.exe, s.newPath); err != nil {
+		return fmt.Errorf("move new executable aside: %w", err)
+	}
+	if err := os.Rename(s.oldPath, s.exe); err != nil {
+		if rerr := os.Rename(s.newPath, s.exe); rerr != nil {
+			return fmt.Errorf("restore previous executable: %v, and putting the new one back failed: %w", err, rerr)
+		}
+		return fmt.Errorf("restore previous executable: %w", err)
+	}
+	os.Remove(s.newPath)
+	return nil
+}
+
+// Restart starts the swapped in executable and waits for it to call
+// ConfirmStartup. If it exits or stays silent for timeout it is killed and
+// the previous executable is put back. On success the caller should quit.
+func (s *Staged) Restart(timeout time.Duration, args ...string) error {
+	marker := s.exe + ".started"
+	os.Remove(marker)
+
+	cmd := exec.Command(s.exe, args...)
+	cmd.Env = append(os.Environ(), markerEnv+"="+marker)
+	if err := cmd.Start(); err != nil {
+		return s.failed(fmt.Errorf("start new version: %w", err))
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(timeout)
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			if _, err := os.Stat(marker); err == nil {
+				os.Remove(marker)
+				return nil
+			}
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited before starting up")
+			}
+			return s.failed(fmt.Errorf("new version failed to start: %w", err))
+		case <-deadline:
+			cmd.Process.Kill()
+			<-exited // the file stays locked until the process is gone
+			return s.failed(fmt.Errorf("new version did not start within %s", timeout))
+		}
+	}
+}
+
+func (s *Staged) failed(err error) error {
+	if rerr := s.Rollback(); rerr != nil {
+		return fmt.Errorf("%v, rollback failed: %w", err, rerr)
+	}
+	return fmt.Errorf("%w, previous version restored", err)
+}
+
+// ConfirmStartup is called by the application once it is up. When started by
+// Restart it only reports back, as the previous executable is still needed
+// if Restart rolls back. Otherwise it removes the executable left behind by
+// the previous update.
+func ConfirmStartup() error {
+	if marker := os.Getenv(markerEnv); marker != "" {
+		os.Unsetenv(markerEnv)
+		return os.WriteFile(marker, nil, 0644)
+	}
+	if exe, err := Executable(); err == nil {
+		os.Remove(exe + ".old")
+	}
+	return nil
+}
+
+// extractExecutable copies the executable out of archive to w. In archives
+// the file named like the running binary wins, otherwise the only .exe (on
+// Windows) or executable file.
+func extractExecutable(archive, exeName string, w io.Writer) error {
+	lower := strings.ToLower(archive)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return extractZip(archive, exeName, w)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return extractTarGz(archive, exeName, w)
+	case strings.HasSuffix(lower, ".exe"):
+		f, err := os.Open(archive)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	}
+	return fmt.Errorf("don't know how to unpack %s", filepath.Base(archive))
+}
+
+func extractZip(archive, exeName string, w io.Writer) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	var found *zip.File
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !isExecutableName(f.Name, f.Mode()) {
+			continue
+		}
+		if found == nil || filepath.Base(f.Name) == exeName {
+			found = f
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("no executable in %s", filepath.Base(archive))
+	}
+	rc, err := found.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+func extractTarGz(archive, exeName string, w io.Writer) error {
+	// tar can't be searched without reading it, so do it in two passes
+	name, err := findInTarGz(archive, exeName)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return err
+		}
+		if hdr.Name == name {
+			_, err = io.Copy(w, tr)
+			return err
+		}
+	}
+}
+
+func findInTarGz(archive, exeName string) (string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return "", err
+	}
+	tr := tar.NewReader(gz)
+	var found string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if hdr.Typeflag != tar.TypeReg || !isExecutableName(hdr.Name, hdr.FileInfo().Mode()) {
+			continue
+		}
+		if found == "" || filepath.Base(hdr.Name) == exeName {
+			found = hdr.Name
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("no executable in %s", filepath.Base(archive))
+	}
+	return found, nil
+}
+
+func isExecutableName(name string, mode os.FileMode) bool {
+	if runtime.GOOS == "windows" {
+		return strings.HasSuffix(strings.ToLower(name), ".exe")
+	}
+	return mode&0111 != 0
+}
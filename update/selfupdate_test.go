@@ -0,0 +1,200 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		hdr.SetMode(0755)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func exeName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+func TestStageSwapRollback(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, exeName("Saab_CIM_Tool_x64"))
+	os.WriteFile(exe, []byte("old"), 0755)
+
+	archive := filepath.Join(dir, "Saab_CIM_Tool_x64.zip")
+	writeZip(t, archive, map[string]string{exeName("Saab_CIM_Tool_x64"): "new"})
+
+	s, err := Stage(exe, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, exe+".new"); got != "new" {
+		t.Fatalf("staged = %q", got)
+	}
+
+	if err := s.Swap(); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, exe) != "new" || readFile(t, exe+".old") != "old" {
+		t.Fatal("swap did not exchange executables")
+	}
+
+	if err := s.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, exe) != "old" {
+		t.Fatal("rollback did not restore the old executable")
+	}
+	if _, err := os.Stat(exe + ".new"); !os.IsNotExist(err) {
+		t.Fatal("rollback left the new executable behind")
+	}
+
+	// Without a previous executable the new one stays in place
+	if s, err = Stage(exe, archive); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Swap(); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(exe + ".old")
+	if err := s.Rollback(); err == nil {
+		t.Fatal("rollback without previous executable succeeded")
+	}
+	if readFile(t, exe) != "new" {
+		t.Fatal("failed rollback left no executable")
+	}
+}
+
+func TestStageTarGz(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("tar.gz releases are not built for windows")
+	}
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "eep")
+	os.WriteFile(exe, []byte("old"), 0755)
+
+	archive := filepath.Join(dir, "eep_2.0.18_Linux_x86_64.tar.gz")
+	f, _ := os.Create(archive)
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, file := range []struct {
+		name, content string
+		mode          int64
+	}{
+		{"README.md", "docs", 0644},
+		{"eep", "new", 0755},
+	} {
+		tw.WriteHeader(&tar.Header{Name: file.name, Mode: file.mode, Size: int64(len(file.content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(file.content))
+	}
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	if _, err := Stage(exe, archive); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, exe+".new"); got != "new" {
+		t.Fatalf("staged = %q", got)
+	}
+}
+
+func TestStageNoExecutable(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, exeName("tool"))
+	archive := filepath.Join(dir, "tool.zip")
+	f, _ := os.Create(archive)
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("README.txt")
+	w.Write([]byte("nothing to run"))
+	zw.Close()
+	f.Close()
+
+	if _, err := Stage(exe, archive); err == nil || !strings.Contains(err.Error(), "no executable") {
+		t.Fatalf("err = %v, want no executable", err)
+	}
+	if _, err := os.Stat(exe + ".new"); !os.IsNotExist(err) {
+		t.Fatal("failed stage left a file behind")
+	}
+}
+
+func TestRestart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts as stand-in executables")
+	}
+	for _, tc := range []struct {
+		name   string
+		script string
+		ok     bool
+	}{
+		{"starts", "#!/bin/sh\ntouch \"$" + markerEnv + "\"\nsleep 1\n", true},
+		{"crashes", "#!/bin/sh\nexit 3\n", false},
+		{"hangs", "#!/bin/sh\nsleep 10\n", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			exe := filepath.Join(dir, "tool")
+			os.WriteFile(exe, []byte("#!/bin/sh\nexit 0\n"), 0755)
+			archive := filepath.Join(dir, "tool.zip")
+			writeZip(t, archive, map[string]string{"tool": tc.script})
+
+			s, err := Stage(exe, archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Swap(); err != nil {
+				t.Fatal(err)
+			}
+			err = s.Restart(500 * time.Millisecond)
+			if tc.ok {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if readFile(t, exe) != tc.script {
+					t.Fatal("new executable not in place")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "previous version restored") {
+				t.Fatalf("err = %v, want rollback", err)
+			}
+			if readFile(t, exe) != "#!/bin/sh\nexit 0\n" {
+				t.Fatal("previous executable not restored")
+			}
+		})
+	}
+}
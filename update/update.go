@@ -1,9 +1,13 @@
 package update
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -83,37 +87,139 @@ type Assets struct {
 	BrowserDownloadURL string      `json:"browser_download_url"`
 }
 
-func GetLatest() (*Release, error) {
-	latest := new(Release)
-	b, err := httpGetBody("https://api.github.com/repos/roffe/eep/releases/latest")
+// DefaultEndpoint is the GitHub API base of the eep repository. Anything
+// serving the same /releases and /releases/latest JSON can stand in for it.
+const DefaultEndpoint = "https://api.github.com/repos/roffe/eep"
+
+// apiTimeout bounds a single API request. Downloads are bounded by the
+// caller's context instead, since assets can take a while on slow links.
+const apiTimeout = 15 * time.Second
+
+type Client struct {
+	endpoint string
+	http     *http.Client
+}
+
+func New(endpoint string) *Client {
+	return &Client{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		http: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: apiTimeout,
+			},
+		},
+	}
+}
+
+// Latest returns the newest non draft, non prerelease release.
+func (c *Client) Latest(ctx context.Context) (*Release, error) {
+	b, err := c.get(ctx, c.endpoint+"/releases/latest")
 	if err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal(b, &latest); err != nil {
-		return nil, err
+	latest := new(Release)
+	if err := json.Unmarshal(b, latest); err != nil {
+		return nil, fmt.Errorf("decode release: %w", err)
 	}
 	return latest, nil
 }
 
-func GetReleases() []*Release {
-	var releases []*Release
-	b, err := httpGetBody("https://api.github.com/repos/roffe/eep/releases")
+// Releases returns all releases, including drafts and prereleases.
+func (c *Client) Releases(ctx context.Context) ([]*Release, error) {
+	b, err := c.get(ctx, c.endpoint+"/releases")
 	if err != nil {
-		return nil
+		return nil, err
 	}
+	var releases []*Release
 	if err := json.Unmarshal(b, &releases); err != nil {
-		return nil
+		return nil, fmt.Errorf("decode releases: %w", err)
+	}
+	return releases, nil
+}
+
+// Download writes the asset to w. onProgress is called as data arrives with
+// the bytes done and the total, which is -1 if the server didn't say.
+func (c *Client) Download(ctx context.Context, asset *Assets, w io.Writer, onProgress func(done, total int64)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, asset.BrowserDownloadURL, nil)
+	if err != nil {
+		return err
 	}
-	return releases
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: %s", asset.Name, resp.Status)
+	}
+
+	total := resp.ContentLength
+	var done int64
+	buf := make([]byte, 32*1024)
+	onProgress(0, total)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			done += int64(n)
+			onProgress(done, total)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("download %s: %w", asset.Name, err)
+		}
+	}
+	if total >= 0 && done != total {
+		return fmt.Errorf("download %s: got %d of %d bytes", asset.Name, done, total)
+	}
+	return nil
 }
 
-func httpGetBody(url string) ([]byte, error) {
-	resp, err := http.Get(url)
-	if resp != nil && resp.Body != nil {
-		defer resp.Body.Close()
+const userAgent = "eep-update"
+
+func (c *Client) get(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.http.Do(req)
 	if err != nil {
 		return nil, err
 	}
-	return io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// GitHub explains rate limits and the like in a JSON message
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(b, &apiErr) == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("GET %s: %s: %s", url, resp.Status, apiErr.Message)
+		}
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return b, nil
+}
+
+func GetLatest() (*Release, error) {
+	return New(DefaultEndpoint).Latest(context.Background())
+}
+
+func GetReleases() ([]*Release, error) {
+	return New(DefaultEndpoint).Releases(context.Background())
 }
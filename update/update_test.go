@@ -0,0 +1,277 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStandIn serves a GitHub like releases API. Assets are downloaded from
+// files by name.
+func newStandIn(t *testing.T, releases []*Release, files map[string][]byte) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	for _, r := range releases {
+		for i := range r.Assets {
+			r.Assets[i].BrowserDownloadURL = srv.URL + "/download/" + r.Assets[i].Name
+		}
+	}
+	mux.HandleFunc("/releases", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(releases)
+	})
+	mux.HandleFunc("/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		for _, rel := range releases {
+			if !rel.Draft && !rel.Prerelease {
+				json.NewEncoder(w).Encode(rel)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[strings.TrimPrefix(r.URL.Path, "/download/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	})
+	return srv
+}
+
+func TestLatestAndReleases(t *testing.T) {
+	srv := newStandIn(t, []*Release{
+		{TagName: "v2.1.0-rc1", Prerelease: true},
+		{TagName: "v2.0.18", Body: "* fixed things"},
+	}, nil)
+	c := New(srv.URL + "/")
+
+	latest, err := c.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.TagName != "v2.0.18" || latest.Body != "* fixed things" {
+		t.Fatalf("latest = %+v", latest)
+	}
+
+	all, err := c.Releases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || !all[0].Prerelease {
+		t.Fatalf("releases = %+v", all)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	srv := newStandIn(t, []*Release{
+		{TagName: "v2.0.9"},
+		{TagName: "v2.1.0-rc1", Prerelease: true},
+		{TagName: "v2.2.0", Draft: true},
+		{TagName: "nightly", Prerelease: true},
+		{TagName: "v2.0.18"},
+	}, nil)
+	c := New(srv.URL)
+
+	for _, tc := range []struct {
+		ch   Channel
+		want string
+		all  []string
+	}{
+		{Stable, "v2.0.18", []string{"v2.0.18", "v2.0.9"}},
+		{Beta, "v2.1.0-rc1", []string{"v2.1.0-rc1", "v2.0.18", "v2.0.9"}},
+	} {
+		latest, err := c.LatestIn(context.Background(), tc.ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest.TagName != tc.want {
+			t.Errorf("%s: latest = %s, want %s", tc.ch, latest.TagName, tc.want)
+		}
+		all, _ := c.Releases(context.Background())
+		var got []string
+		for _, rel := range tc.ch.Filter(all) {
+			got = append(got, rel.TagName)
+		}
+		if strings.Join(got, " ") != strings.Join(tc.all, " ") {
+			t.Errorf("%s: releases = %v, want %v", tc.ch, got, tc.all)
+		}
+	}
+
+	empty := New(newStandIn(t, []*Release{{TagName: "v3.0.0-beta", Prerelease: true}}, nil).URL)
+	if _, err := empty.LatestIn(context.Background(), Stable); !errors.Is(err, ErrNoRelease) {
+		t.Errorf("err = %v, want ErrNoRelease", err)
+	}
+	if ParseChannel("beta") != Beta || ParseChannel("") != Stable {
+		t.Error("ParseChannel")
+	}
+}
+
+func TestStatusErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"API rate limit exceeded"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).Latest(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden: API rate limit exceeded") {
+		t.Fatalf("err = %v, want rate limit error", err)
+	}
+
+	srv404 := httptest.NewServer(http.NotFoundHandler())
+	defer srv404.Close()
+	if _, err := New(srv404.URL).Releases(context.Background()); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("err = %v, want 404", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := New(srv.URL).Latest(ctx); err == nil {
+		t.Fatal("expected timeout")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	payload := bytes.Repeat([]byte("eep"), 50000)
+	srv := newStandIn(t, []*Release{
+		{TagName: "v2.0.18", Assets: []Assets{{Name: "Saab_CIM_Tool_x64.zip"}}},
+	}, map[string][]byte{"Saab_CIM_Tool_x64.zip": payload})
+	c := New(srv.URL)
+
+	latest, err := c.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	var lastDone, lastTotal int64
+	if err := c.Download(context.Background(), &latest.Assets[0], &out, func(done, total int64) {
+		lastDone, lastTotal = done, total
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), payload) {
+		t.Fatal("downloaded content mismatch")
+	}
+	if lastDone != int64(len(payload)) || lastTotal != int64(len(payload)) {
+		t.Fatalf("progress ended at %d/%d", lastDone, lastTotal)
+	}
+}
+
+func TestAsset(t *testing.T) {
+	rel := &Release{TagName: "v2.0.18", Assets: []Assets{
+		{Name: "checksums.txt"},
+		{Name: "Saab_CIM_Tool_x86.zip"},
+		{Name: "Saab_CIM_Tool_x64.zip"},
+		{Name: "eep_2.0.18_Linux_x86_64.tar.gz"},
+		{Name: "eep_2.0.18_Darwin_arm64.tar.gz"},
+	}}
+	for _, tc := range []struct{ goos, goarch, want string }{
+		{"windows", "amd64", "Saab_CIM_Tool_x64.zip"},
+		{"windows", "386", "Saab_CIM_Tool_x86.zip"},
+		{"linux", "amd64", "eep_2.0.18_Linux_x86_64.tar.gz"},
+		{"darwin", "arm64", "eep_2.0.18_Darwin_arm64.tar.gz"},
+	} {
+		a, err := rel.Asset(tc.goos, tc.goarch)
+		if err != nil {
+			t.Fatalf("%s/%s: %v", tc.goos, tc.goarch, err)
+		}
+		if a.Name != tc.want {
+			t.Fatalf("%s/%s = %s, want %s", tc.goos, tc.goarch, a.Name, tc.want)
+		}
+	}
+	if _, err := rel.Asset("linux", "arm64"); err == nil {
+		t.Fatal("expected no asset for linux/arm64")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	payload := []byte("new cim tool")
+	sum := sha256.Sum256(payload)
+	sums := []byte(hex.EncodeToString(sum[:]) + "  Saab_CIM_Tool_x64.zip\r\n" +
+		strings.Repeat("0", 64) + "  Saab_CIM_Tool_x86.zip\r\n")
+
+	rel := &Release{TagName: "v2.0.18", Assets: []Assets{
+		{Name: "Saab_CIM_Tool_x64.zip"},
+		{Name: "Saab_CIM_Tool_x86.zip"},
+		{Name: "checksums.txt"},
+	}}
+	srv := newStandIn(t, []*Release{rel}, map[string][]byte{"checksums.txt": sums})
+	c := New(srv.URL)
+
+	if err := c.Verify(context.Background(), rel, &rel.Assets[0], bytes.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	var sumErr *ChecksumError
+	err := c.Verify(context.Background(), rel, &rel.Assets[0], bytes.NewReader([]byte("tampered")))
+	if !errors.As(err, &sumErr) || sumErr.Name != "Saab_CIM_Tool_x64.zip" {
+		t.Fatalf("err = %v, want checksum mismatch", err)
+	}
+
+	noSums := &Release{TagName: "v2.0.18", Assets: rel.Assets[:2]}
+	if err := c.Verify(context.Background(), noSums, &rel.Assets[0], bytes.NewReader(payload)); !errors.Is(err, ErrNoChecksums) {
+		t.Fatalf("err = %v, want ErrNoChecksums", err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	pubKey := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...))
+
+	sign := func(data []byte, trusted string) []byte {
+		sig := ed25519.Sign(priv, data)
+		global := ed25519.Sign(priv, append(append([]byte{}, sig...), trusted...))
+		return []byte("untrusted comment: signature\n" +
+			base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), sig...)) + "\n" +
+			"trusted comment: " + trusted + "\n" +
+			base64.StdEncoding.EncodeToString(global) + "\n")
+	}
+
+	data := []byte("checksums")
+	sig := sign(data, "timestamp:1 file:checksums.txt")
+	if err := VerifySignature(pubKey, data, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignature(pubKey, []byte("checksumz"), sig); err == nil {
+		t.Fatal("expected failure on modified data")
+	}
+	forged := bytes.Replace(sig, []byte("timestamp:1"), []byte("timestamp:2"), 1)
+	if err := VerifySignature(pubKey, data, forged); err == nil {
+		t.Fatal("expected failure on modified trusted comment")
+	}
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	otherKey := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), otherPub...))
+	if err := VerifySignature(otherKey, data, sig); err == nil {
+		t.Fatal("expected failure with another key")
+	}
+}
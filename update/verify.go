@@ -0,0 +1,190 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	checksumsName = "checksums.txt"
+	signatureName = checksumsName + ".minisig"
+)
+
+// PublicKey is the minisign public key release checksums are signed with. It
+// is set at build time with
+//
+//	-ldflags "-X github.com/roffe/eep/update.PublicKey=RWQ..."
+//
+// Once set, releases without a valid checksums.txt.minisig are rejected.
+// Only legacy (non prehashed) signatures are supported, sign with
+// `minisign -S -l -m checksums.txt`.
+var PublicKey = ""
+
+var ErrNoChecksums = errors.New("release has no " + checksumsName + ", the download can't be verified")
+
+// ChecksumError is returned when a download doesn't match checksums.txt.
+type ChecksumError struct {
+	Name     string
+	Expected string
+	Got      string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %s, got %s", e.Name, e.Expected, e.Got)
+}
+
+// Verify checks the downloaded asset in r against the release's
+// checksums.txt, and checks the signature of checksums.txt when PublicKey is
+// set.
+func (c *Client) Verify(ctx context.Context, rel *Release, asset *Assets, r io.Reader) error {
+	sums, err := c.fetchAsset(ctx, rel, checksumsName)
+	if err != nil {
+		return err
+	}
+	if PublicKey != "" {
+		sig, err := c.fetchAsset(ctx, rel, signatureName)
+		if err != nil {
+			return err
+		}
+		if err := VerifySignature(PublicKey, sums, sig); err != nil {
+			return fmt.Errorf("%s: %w", checksumsName, err)
+		}
+	}
+
+	checksums, err := ParseChecksums(sums)
+	if err != nil {
+		return err
+	}
+	expected, ok := checksums[asset.Name]
+	if !ok {
+		return fmt.Errorf("%s has no entry for %s", checksumsName, asset.Name)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != expected {
+		return &ChecksumError{Name: asset.Name, Expected: expected, Got: got}
+	}
+	return nil
+}
+
+// fetchAsset downloads a small release asset like checksums.txt into memory.
+func (c *Client) fetchAsset(ctx context.Context, rel *Release, name string) ([]byte, error) {
+	for i := range rel.Assets {
+		if rel.Assets[i].Name != name {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := c.Download(ctx, &rel.Assets[i], &buf, func(int64, int64) {}); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	if name == checksumsName {
+		return nil, ErrNoChecksums
+	}
+	return nil, fmt.Errorf("release %s has no %s", rel.TagName, name)
+}
+
+// ParseChecksums reads sha256sum style lines ("<hex>  <name>") into a map of
+// file name to lower case hex digest.
+func ParseChecksums(data []byte) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("bad checksum line %q", line)
+		}
+		sum := strings.ToLower(fields[0])
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("bad sha256 in line %q", line)
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = sum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// VerifySignature checks a minisign signature of data, including the signed
+// trusted comment. See https://jedisct1.github.io/minisign/ for the format.
+func VerifySignature(publicKey string, data, signature []byte) error {
+	keyID, pk, err := parseMinisignKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	lines := nonEmptyLines(string(signature))
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed signature file")
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 74 {
+		return errors.New("malformed signature")
+	}
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		return errors.New("prehashed signatures are not supported, sign with minisign -l")
+	default:
+		return errors.New("unknown signature algorithm")
+	}
+	if !bytes.Equal(sig[2:10], keyID[:]) {
+		return fmt.Errorf("signed with key %X, expected %X", sig[2:10], keyID)
+	}
+	if !ed25519.Verify(pk, data, sig[10:]) {
+		return errors.New("signature verification failed")
+	}
+
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return errors.New("malformed trusted comment signature")
+	}
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(pk, append(append([]byte{}, sig[10:]...), trusted...), globalSig) {
+		return errors.New("trusted comment signature verification failed")
+	}
+	return nil
+}
+
+// parseMinisignKey accepts either the base64 key line or a whole .pub file.
+func parseMinisignKey(s string) ([8]byte, ed25519.PublicKey, error) {
+	var keyID [8]byte
+	lines := nonEmptyLines(s)
+	if len(lines) == 0 {
+		return keyID, nil, errors.New("empty public key")
+	}
+	b, err := base64.StdEncoding.DecodeString(lines[len(lines)-1])
+	if err != nil || len(b) != 42 || string(b[:2]) != "Ed" {
+		return keyID, nil, errors.New("malformed public key")
+	}
+	copy(keyID[:], b[2:10])
+	return keyID, ed25519.PublicKey(b[10:]), nil
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
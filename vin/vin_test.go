@@ -0,0 +1,109 @@
+package vin
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckDigit(t *testing.T) {
+	for vin, want := range map[string]byte{
+		"1M8GDM9AXKP042788": 'X',
+		"11111111111111111": '1',
+		"YS3FD49Y081234567": '7',
+	} {
+		got, err := CheckDigit(vin)
+		if err != nil || got != want {
+			t.Errorf("CheckDigit(%s) = %c, %v, want %c", vin, got, err, want)
+		}
+	}
+	if _, err := CheckDigit("YS3FD49Y08123456"); !errors.Is(err, ErrLength) {
+		t.Errorf("short VIN: %v", err)
+	}
+	if _, err := CheckDigit("YS3FD49Y0812345O7"); !errors.Is(err, ErrChar) {
+		t.Errorf("VIN with O: %v", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	info, err := Decode(" ys3fd49y781234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Info{
+		VIN:          "YS3FD49Y781234567",
+		WMI:          "YS3",
+		Manufacturer: "Saab Automobile AB, Sweden",
+		Line:         "9-3 (2003-2012)",
+		Series:       "Arc / Vector",
+		Body:         "4-door sedan",
+		Restraint:    "9",
+		Engine:       "2.0 turbo, B207",
+		Check:        '7',
+		Expected:     '7',
+		Year:         2008,
+		Plant:        "Trollhättan, Sweden",
+		Serial:       "234567",
+	}
+	if *info != want {
+		t.Errorf("Decode = %+v\nwant %+v", *info, want)
+	}
+	if !info.CheckOK() || Validate(info.VIN) != nil {
+		t.Error("check digit not accepted")
+	}
+
+	if _, err := Decode("YS3FZ49QA2A123456"); err == nil {
+		t.Fatal("Q accepted")
+	}
+	info, err = Decode("YS3FZ49ZAA9123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Series != "unknown (Z)" || info.Plant != "unknown (9)" || info.Year != 2010 {
+		t.Errorf("Decode = %+v", info)
+	}
+	if err := Validate(info.VIN); !errors.Is(err, ErrCheckDigit) {
+		t.Errorf("Validate = %v", err)
+	}
+}
+
+func TestYear(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	for c, want := range map[byte]int{'Y': 2000, '1': 2001, '9': 2009, 'A': 2010, 'E': 2014, 'F': 1985, 'T': 1996, 'V': 1997, 'W': 1998, 'I': 0} {
+		if got := year("YS3", c); got != want {
+			t.Errorf("year(YS3, %c) = %d, want %d", c, got, want)
+		}
+	}
+	// Other makers' codes roll over up to next year
+	for c, want := range map[byte]int{'1': 2001, 'F': 2015, 'T': 2026, 'V': 2027, 'W': 1998} {
+		if got := year("1M8", c); got != want {
+			t.Errorf("year(1M8, %c) = %d, want %d", c, got, want)
+		}
+	}
+}
+
+func TestSaabVINs(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	for vin, want := range map[string]struct {
+		year          int
+		engine, plant string
+	}{
+		"YS3CH55G4T1012345": {1996, "2.3 turbo, B235R", "Trollhättan, Sweden"},
+		"YS3DF78K417012345": {2001, "2.3 turbo, B235R", "Uusikaupunki, Finland"},
+		"YS3FH41U561012345": {2006, "2.8 V6 turbo, B284", "Trollhättan, Sweden"},
+		"5S3ET13S752012345": {2005, "unknown (S)", "unknown (2)"},
+	} {
+		info, err := Decode(vin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Year != want.year || info.Engine != want.engine || info.Plant != want.plant || !info.CheckOK() {
+			t.Errorf("Decode(%s) = %d, %s, %s, check %c want %c", vin, info.Year, info.Engine, info.Plant, info.Check, info.Expected)
+		}
+	}
+}